@@ -0,0 +1,67 @@
+package remotefilez
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/stretchr/testify/require"
+)
+
+// staticCredential is a credential which is never used to make requests.
+type staticCredential struct{}
+
+func (staticCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestNewBlobClient(t *testing.T) {
+	const blobURL = "abs://acct.blob.core.windows.net/c/blob"
+	const snapshot = "2023-01-02T03:04:05.0000000Z"
+	parse := func(t *testing.T, rawURL string) *url.URL {
+		c, err := newBlobClient(rawURL, staticCredential{})
+		require.NoError(t, err)
+		u, err := url.Parse(c.URL())
+		require.NoError(t, err)
+		require.Equal(t, "https", u.Scheme)
+		require.Equal(t, "/c/blob", u.Path)
+		return u
+	}
+
+	t.Run("plain", func(t *testing.T) {
+		u := parse(t, blobURL)
+		require.Empty(t, u.RawQuery)
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		u := parse(t, blobURL+"?snapshot="+url.QueryEscape(snapshot))
+		require.Equal(t, url.Values{querySnapshot: {snapshot}}, u.Query())
+	})
+
+	t.Run("version", func(t *testing.T) {
+		u := parse(t, blobURL+"?versionid=v1")
+		require.Equal(t, url.Values{queryVersionID: {"v1"}}, u.Query())
+	})
+
+	t.Run("snapshot and version", func(t *testing.T) {
+		_, err := newBlobClient(blobURL+"?snapshot=s1&versionid=v1", staticCredential{})
+		require.ErrorIs(t, err, ErrInvalidBlobURL)
+	})
+
+	t.Run("range fragment is dropped", func(t *testing.T) {
+		u := parse(t, blobURL+"?versionid=v1#bytes=0-9")
+		require.Empty(t, u.Fragment)
+		require.Equal(t, url.Values{queryVersionID: {"v1"}}, u.Query())
+	})
+
+	t.Run("writers reject versions", func(t *testing.T) {
+		ctx := context.Background()
+		for _, q := range []string{"?snapshot=s1", "?versionid=v1"} {
+			_, err := newAzureBlobWriter(ctx, blobURL+q, staticCredential{}, &WriterOptions{})
+			require.ErrorIs(t, err, ErrInvalidBlobURL)
+		}
+	})
+}
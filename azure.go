@@ -2,10 +2,13 @@ package remotefilez
 
 import (
 	"context"
+	"crypto/md5"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"net/url"
+	"os"
 	"regexp"
 	"sync"
 	"sync/atomic"
@@ -13,6 +16,7 @@ import (
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
 	"golang.org/x/exp/constraints"
 )
@@ -22,8 +26,17 @@ var _ io.Closer = (*azReader)(nil)
 var _ io.Reader = (*azReader)(nil)
 var _ io.ReaderAt = (*azReader)(nil)
 var _ interface{ Size() (int64, error) } = (*azReader)(nil)
+var _ rangeReader = (*azReader)(nil)
+var _ Stater = (*azReader)(nil)
+var _ io.WriterTo = (*azReader)(nil)
+var _ ContextReader = (*azReader)(nil)
+var _ contextWriterTo = (*azReader)(nil)
+var _ verifiable = (*azReader)(nil)
 var _ io.Closer = (*azWriter)(nil)
 var _ io.Writer = (*azWriter)(nil)
+var _ io.ReaderFrom = (*azWriter)(nil)
+var _ WriteAborter = (*azWriter)(nil)
+var _ Resumer = (*azWriter)(nil)
 
 var (
 	ErrInvalidBlobURL = errors.New("invalid blob url")
@@ -37,37 +50,75 @@ type accounting struct {
 	readAtSlowpath uint32
 }
 
+// blobDownloader is the part of a blob client used by azReader.
+type blobDownloader interface {
+	DownloadStream(ctx context.Context, o *blob.DownloadStreamOptions) (blob.DownloadStreamResponse, error)
+	URL() string
+}
+
 type azReader struct {
-	blob *blob.Client
+	blob blobDownloader
 	resp *blob.DownloadStreamResponse
 	mtx  sync.Mutex
+	etag azcore.ETag
+	info FileInfo
+	base int64
 	n    int64
 	off  int64
-	err  error
-	ctx  context.Context
 
+	// streamCancel aborts the current download stream. It is guarded by
+	// streamMtx rather than mtx so that a per-operation context can abort an
+	// operation which holds mtx.
+	streamMtx    sync.Mutex
+	streamCancel context.CancelFunc
+
+	retry  RetryPolicy
 	doAcct bool
 	acct   accounting
+
+	// verify is set if the blob is verified against its stored MD5, by
+	// verifier for sequential reads of sc.
+	verify   bool
+	verifier *readVerifier
 }
 
+// azReaderOptions contains optional parameters for azure blob readers.
+type azReaderOptions struct {
+	rng    ByteRange
+	retry  RetryPolicy
+	doAcct bool
+	verify bool
+}
+
+// NewAzureBlobReader opens the blob at blobURL for reading. The provided
+// context governs the open call only, see ContextReader for per-operation
+// contexts.
 func NewAzureBlobReader(
 	ctx context.Context,
 	blobURL string,
 	creds azcore.TokenCredential,
 	openTimeout time.Duration,
 	doAcct bool,
+) (*azReader, error) {
+	return newAzureBlobReader(ctx, blobURL, creds, azReaderOptions{
+		rng:    fullRange,
+		retry:  defaultRetryPolicy,
+		doAcct: doAcct,
+	})
+}
+
+func newAzureBlobReader(
+	ctx context.Context,
+	blobURL string,
+	creds azcore.TokenCredential,
+	opts azReaderOptions,
 ) (*azReader, error) {
 	if creds == nil {
 		return nil, errors.New("nil credentials")
 	}
-	u, err := url.Parse(blobURL)
-	if err != nil {
-		return nil, ErrInvalidBlobURL
-	}
-	u.Scheme = "https"
 
 	// Initialize client
-	blobClient, err := blob.NewClient(u.String(), creds, nil)
+	blobClient, err := newBlobClient(blobURL, creds)
 	if err != nil {
 		return nil, err
 	}
@@ -77,26 +128,121 @@ func NewAzureBlobReader(
 	if err != nil {
 		return nil, err
 	}
-	if resp.ContentLength == nil || *resp.ContentLength == 0 {
+	if resp.ContentLength == nil {
 		return nil, errors.New("unexpected: nil blob length")
 	}
 
 	// Init
 	var sc azReader
-	sc.n = *resp.ContentLength
-	sc.blob = blobClient
-	sc.ctx = ctx
-	if _, err := sc.Seek(0, io.SeekStart); err != nil {
+	sc.base, sc.n, err = opts.rng.clamp(*resp.ContentLength)
+	if err != nil {
 		return nil, err
 	}
+	sc.blob = blobClient
+	if resp.ETag != nil {
+		sc.etag = *resp.ETag
+	}
+	sc.info = azFileInfo(resp)
+	sc.retry = opts.retry.withDefaults()
+	sc.verify = opts.verify && sc.base == 0 && sc.n == *resp.ContentLength
+	sc.verifier = sc.newVerifier()
 
-	if doAcct {
+	if opts.doAcct {
 		sc.doAcct = true
 	}
 
 	return &sc, nil
 }
 
+// newBlobClient returns a client for the blob at blobURL. If the URL has a
+// snapshot or versionid query parameter, the client reads that specific,
+// immutable snapshot or version of the blob.
+func newBlobClient(blobURL string, creds azcore.TokenCredential) (*blob.Client, error) {
+	u, err := url.Parse(blobURL)
+	if err != nil {
+		return nil, ErrInvalidBlobURL
+	}
+	u.Scheme = "https"
+	u.Fragment = ""
+	q := u.Query()
+	snapshot := q.Get(querySnapshot)
+	versionID := q.Get(queryVersionID)
+	if snapshot != "" && versionID != "" {
+		return nil, fmt.Errorf("%w, both snapshot and versionid provided", ErrInvalidBlobURL)
+	}
+	q.Del(querySnapshot)
+	q.Del(queryVersionID)
+	u.RawQuery = q.Encode()
+
+	blobClient, err := blob.NewClient(u.String(), creds, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case snapshot != "":
+		return blobClient.WithSnapshot(snapshot)
+	case versionID != "":
+		return blobClient.WithVersionID(versionID)
+	}
+	return blobClient, nil
+}
+
+// statAzureBlob returns the properties of the blob at blobURL.
+func statAzureBlob(
+	ctx context.Context,
+	blobURL string,
+	creds azcore.TokenCredential,
+) (FileInfo, error) {
+	blobClient, err := newBlobClient(blobURL, creds)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	resp, err := blobClient.GetProperties(ctx, nil)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return azFileInfo(resp), nil
+}
+
+// azFileInfo converts blob properties to FileInfo.
+func azFileInfo(resp blob.GetPropertiesResponse) FileInfo {
+	var fi FileInfo
+	fi.Extra = make(map[string]string)
+	if resp.ContentLength != nil {
+		fi.Size = *resp.ContentLength
+	}
+	if resp.LastModified != nil {
+		fi.ModTime = *resp.LastModified
+	}
+	if resp.ETag != nil {
+		fi.ETag = string(*resp.ETag)
+	}
+	if resp.ContentType != nil {
+		fi.ContentType = *resp.ContentType
+	}
+	if resp.ContentEncoding != nil {
+		fi.ContentEncoding = *resp.ContentEncoding
+	}
+	fi.ContentMD5 = resp.ContentMD5
+	fi.Metadata = resp.Metadata
+	if resp.CacheControl != nil {
+		fi.Extra["CacheControl"] = *resp.CacheControl
+	}
+	if resp.AccessTier != nil {
+		fi.Extra["AccessTier"] = *resp.AccessTier
+	}
+	if resp.BlobType != nil {
+		fi.Extra["BlobType"] = string(*resp.BlobType)
+	}
+	if resp.VersionID != nil {
+		fi.Extra["VersionID"] = *resp.VersionID
+	}
+	if resp.CreationTime != nil {
+		fi.Extra["CreationTime"] = resp.CreationTime.Format(time.RFC3339)
+	}
+	return fi
+}
+
 // Read reads up to len(p) bytes into p. It returns the number of bytes
 // read (0 <= n <= len(p)) and any error encountered. Even if Read
 // returns n < len(p), it may use all of p as scratch space during the call.
@@ -124,19 +270,29 @@ func NewAzureBlobReader(
 //
 // Implementations must not retain p.
 func (sc *azReader) Read(p []byte) (n int, err error) {
+	return sc.ReadCtx(context.Background(), p)
+}
 
+// ReadCtx is like Read, but aborts the read if ctx is done.
+func (sc *azReader) ReadCtx(ctx context.Context, p []byte) (n int, err error) {
 	sc.mtx.Lock()
 	defer sc.mtx.Unlock()
-	return sc.read(p)
+	defer sc.abortOnDone(ctx)()
+	return sc.read(ctx, p)
 }
 
 func (sc *azReader) Size() (int64, error) {
 	return sc.n, nil
 }
 
+// FileInfo returns the properties of the blob as of when it was opened.
+func (sc *azReader) FileInfo() (FileInfo, error) {
+	return sc.info, nil
+}
+
 // read is a concurrency-unsafe version of .Read(). You must hold sc.mtx before
 // calling this function.
-func (sc *azReader) read(p []byte) (n int, err error) {
+func (sc *azReader) read(ctx context.Context, p []byte) (n int, err error) {
 	sc.accountRead(p)
 
 	if len(p) == 0 {
@@ -145,22 +301,123 @@ func (sc *azReader) read(p []byte) (n int, err error) {
 	if sc.off >= sc.n {
 		return 0, io.EOF
 	}
-	if sc.err != nil {
-		return 0, sc.err
+	if err := ctx.Err(); err != nil {
+		return 0, err
 	}
 	if sc.resp == nil {
-		return 0, errors.New("unexpected: nil resp")
+		// The download stream is opened lazily so that readers which are
+		// never read from (e.g. disk cache hits) do not start a download.
+		if err := sc.openStream(); err != nil {
+			return 0, sc.ctxErr(ctx, err)
+		}
 	}
-	n, err = sc.resp.Body.Read(p)
-	sc.off += int64(n)
-	if err == io.EOF {
-		r := sc.resp
-		sc.resp = nil
-		if err := r.Body.Close(); err != nil {
+	for attempt := 0; ; attempt++ {
+		n, err = sc.resp.Body.Read(p)
+		sc.verifier.add(p[:n], sc.off)
+		sc.off += int64(n)
+		if err := sc.verifier.verify(); err != nil {
+			return n, err
+		}
+		if err == nil {
+			return n, nil
+		}
+		if err == io.EOF {
+			if err := sc.dropStream(); err != nil {
+				return 0, err
+			}
+			return n, nil
+		}
+
+		// The stream is broken, or was aborted by ctx. Drop it and, unless
+		// some data was read, try to resume at the current offset.
+		sc.dropStream()
+		if n > 0 {
+			return n, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if !sc.retry.retryable(err, attempt) {
+			return 0, err
+		}
+		if err := sc.retry.wait(ctx, attempt); err != nil {
 			return 0, err
 		}
+		if err := sc.openStream(); err != nil {
+			return 0, sc.ctxErr(ctx, err)
+		}
 	}
-	return n, nil
+}
+
+// openStream opens a download stream from the current offset. The stream is
+// not bound to the context of any single operation, but may be aborted by
+// abortOnDone. You must hold sc.mtx before calling this function.
+func (sc *azReader) openStream() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	sc.streamMtx.Lock()
+	sc.streamCancel = cancel
+	sc.streamMtx.Unlock()
+
+	resp, err := sc.download(ctx, sc.off, sc.n-sc.off)
+	if err != nil {
+		sc.dropStream()
+		return err
+	}
+	sc.resp = &resp
+	return nil
+}
+
+// dropStream closes the current download stream, if any. You must hold sc.mtx
+// before calling this function.
+func (sc *azReader) dropStream() error {
+	var err error
+	if sc.resp != nil {
+		err = sc.resp.Body.Close()
+		sc.resp = nil
+	}
+	sc.streamMtx.Lock()
+	if sc.streamCancel != nil {
+		sc.streamCancel()
+		sc.streamCancel = nil
+	}
+	sc.streamMtx.Unlock()
+	return err
+}
+
+// abortOnDone aborts the current download stream if ctx is done before the
+// returned function is called. This lets a per-operation context interrupt a
+// blocked read without binding the stream to that context.
+func (sc *azReader) abortOnDone(ctx context.Context) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			sc.streamMtx.Lock()
+			if sc.streamCancel != nil {
+				sc.streamCancel()
+			}
+			sc.streamMtx.Unlock()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// ctxErr returns the error of ctx if it is done, since err is then most likely
+// the result of the stream being aborted.
+func (sc *azReader) ctxErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return ctxErr
+	}
+	return err
 }
 
 func (sc *azReader) accountRead(p []byte) {
@@ -184,19 +441,139 @@ func (sc *azReader) accountRead(p []byte) {
 }
 
 func (sc *azReader) ReadAt(p []byte, off int64) (n int, err error) {
+	return sc.ReadAtCtx(context.Background(), p, off)
+}
+
+// ReadAtCtx is like ReadAt, but aborts the read if ctx is done.
+func (sc *azReader) ReadAtCtx(ctx context.Context, p []byte, off int64) (n int, err error) {
 	sc.mtx.Lock()
 	defer sc.mtx.Unlock()
+	defer sc.abortOnDone(ctx)()
 	if off == sc.off {
 		// fastpath
 		sc.acct.readAtFastpath++
-		return sc.read(p)
+		return sc.read(ctx, p)
 	} else {
 		sc.acct.readAtSlowpath++
 	}
 	if _, err := sc.seek(off, io.SeekStart); err != nil {
 		return 0, err
 	}
-	return sc.read(p)
+	return sc.read(ctx, p)
+}
+
+// readRange reads len(p) bytes starting at off using a dedicated ranged
+// request. It neither uses nor modifies the reader's stream and offset, and is
+// therefore safe for concurrent use.
+func (sc *azReader) readRange(ctx context.Context, p []byte, off int64) (int, error) {
+	if off >= sc.n {
+		return 0, io.EOF
+	}
+	count := min(int64(len(p)), sc.n-off)
+	if count == 0 {
+		return 0, nil
+	}
+
+	var n, failures int
+	for {
+		resp, err := sc.download(ctx, off+int64(n), count-int64(n))
+		if err != nil {
+			return n, err
+		}
+		m, err := io.ReadFull(resp.Body, p[n:count])
+		resp.Body.Close()
+		n += m
+		if err == nil {
+			break
+		}
+
+		// The stream is broken, resume at the current offset.
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return n, ctxErr
+		}
+		if m > 0 {
+			failures = 0
+		}
+		if !sc.retry.retryable(err, failures) {
+			return n, err
+		}
+		if err := sc.retry.wait(ctx, failures); err != nil {
+			return n, err
+		}
+		failures++
+	}
+	if int64(n) < int64(len(p)) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// download starts a download of count bytes from off, relative to the range
+// of the blob being read. The request is pinned to
+// the ETag captured when the reader was opened, and fails with
+// ErrObjectChanged if the blob has since been modified.
+func (sc *azReader) download(
+	ctx context.Context,
+	off, count int64,
+) (blob.DownloadStreamResponse, error) {
+	var o blob.DownloadStreamOptions
+	o.Range.Offset = sc.base + off
+	o.Range.Count = count
+	if sc.etag != "" {
+		o.AccessConditions = &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{
+				IfMatch: &sc.etag,
+			},
+		}
+	}
+	resp, err := sc.blob.DownloadStream(ctx, &o)
+	if bloberror.HasCode(err, bloberror.ConditionNotMet) {
+		return resp, fmt.Errorf("%w, etag %v no longer matches", ErrObjectChanged, sc.etag)
+	}
+	return resp, err
+}
+
+// WriteTo writes the remainder of the blob to w. The blob is downloaded in
+// large chunks using several concurrent ranged requests.
+func (sc *azReader) WriteTo(w io.Writer) (int64, error) {
+	return sc.writeTo(context.Background(), w)
+}
+
+// writeTo is like WriteTo, but stops once ctx is done.
+func (sc *azReader) writeTo(ctx context.Context, w io.Writer) (int64, error) {
+	sc.mtx.Lock()
+	defer sc.mtx.Unlock()
+	sc.dropStream()
+
+	var written int64
+	err := fetchChunks(ctx, sc.readRange, sc.off, sc.n,
+		defaultChunkSize, defaultConcurrency,
+		func(p []byte, off int64) error {
+			sc.verifier.add(p, off)
+			n, err := w.Write(p)
+			written += int64(n)
+			sc.off += int64(n)
+			return err
+		},
+	)
+	if err == nil {
+		err = sc.verifier.verify()
+	}
+	return written, err
+}
+
+// newVerifier implements verifiable.
+func (sc *azReader) newVerifier() *readVerifier {
+	if !sc.verify {
+		return nil
+	}
+	return newReadVerifier(sc.info.ContentMD5, sc.n)
+}
+
+// objectKey returns the blob URL, ETag and range, which together identify the
+// version and range of the blob being read.
+func (sc *azReader) objectKey() string {
+	return fmt.Sprintf("%v@%v#bytes=%v-%v", sc.blob.URL(), sc.etag, sc.base, sc.base+sc.n)
 }
 
 func (sc *azReader) readAtAccount(p []byte, off int64) {
@@ -229,9 +606,18 @@ func (sc *azReader) readAtAccount(p []byte, off int64) {
 // the size of the underlying object the behavior of subsequent I/O operations
 // is implementation-dependent.
 func (sc *azReader) Seek(offset int64, whence int) (int64, error) {
+	return sc.SeekCtx(context.Background(), offset, whence)
+}
+
+// SeekCtx is like Seek, but aborts the seek if ctx is done. Seeking never
+// issues new requests; the download stream is reopened lazily on the next
+// read.
+func (sc *azReader) SeekCtx(ctx context.Context, offset int64, whence int) (int64, error) {
 	sc.mtx.Lock()
 	defer sc.mtx.Unlock()
-	return sc.seek(offset, whence)
+	defer sc.abortOnDone(ctx)()
+	off, err := sc.seek(offset, whence)
+	return off, sc.ctxErr(ctx, err)
 }
 
 // seek is a concurrency-unsafe version of .Seek(). You must hold sc.mtx before
@@ -239,70 +625,33 @@ func (sc *azReader) Seek(offset int64, whence int) (int64, error) {
 func (sc *azReader) seek(offset int64, whence int) (int64, error) {
 	switch whence {
 	case io.SeekStart:
-
-		if err := sc.close(); err != nil {
-			return 0, err
-		}
-
-		if offset < 0 {
-			return 0, errors.New("offset out of bounds")
-		}
-		sc.off = offset
-		if sc.off >= sc.n {
-			return sc.off, nil
+	case io.SeekCurrent:
+		if offset > 0 && offset <= sc.n-sc.off && sc.resp != nil {
+			// FFWD
+			m, err := io.CopyN(io.Discard, sc.resp.Body, offset)
+			sc.off += m
+			offset -= m
+			if err == nil {
+				return sc.off, nil
+			}
+			// The stream is broken, it is reopened on the next read
 		}
-
-		var o blob.DownloadStreamOptions
-		o.Range.Count = sc.n - sc.off
-		o.Range.Offset = sc.off
-
-		resp, err := sc.blob.DownloadStream(sc.ctx, &o)
-		sc.resp = &resp
-		sc.err = err
-
-		return sc.off, sc.err
-
+		offset += sc.off
 	case io.SeekEnd:
-		if err := sc.close(); err != nil {
+		offset += sc.n
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("offset out of bounds")
+	}
+	if offset != sc.off {
+		if err := sc.dropStream(); err != nil {
 			return 0, err
 		}
-
-		if sc.n+offset < 0 {
-			return 0, errors.New("offset out of bounds")
-		}
-		if sc.off+offset > sc.n {
-			return offset + sc.n, nil
-		}
-		sc.off += offset
-		if sc.off == sc.n {
-			return sc.off, nil
-		}
-
-		var o blob.DownloadStreamOptions
-		o.Range.Count = sc.n - sc.off
-		o.Range.Offset = sc.off
-
-		resp, err := sc.blob.DownloadStream(sc.ctx, &o)
-		sc.resp = &resp
-		sc.err = err
-
-		return sc.off, sc.err
-
-	case io.SeekCurrent:
-		if offset >= 0 {
-			if sc.resp != nil {
-				// FFWD
-				gap := min(offset, sc.n-sc.off)
-				m, err := io.CopyN(io.Discard, sc.resp.Body, gap)
-				sc.off += m
-				return sc.off, err
-			}
-			return sc.seek(sc.off+offset, io.SeekStart)
-		}
-		return sc.seek(sc.off+offset, io.SeekStart)
+		sc.off = offset
 	}
-
-	return 0, errors.New("invalid whence")
+	return sc.off, nil
 }
 
 // Close closes the underlying blob connection.
@@ -315,21 +664,19 @@ func (sc *azReader) Close() error {
 // close is a concurrency-unsafe version of .Close(). You must hold sc.mtx before
 // calling this function.
 func (sc *azReader) close() error {
-	if sc.resp != nil {
-		r := sc.resp
-		sc.resp = nil
-		sc.off = 0
-		return r.Body.Close()
-	}
-	return nil
+	return sc.dropStream()
 }
 
 type azWriter struct {
-	blob *blockblob.Client
-	mtx  sync.Mutex
-	n    int64
-	err  error
-	w    io.WriteCloser
+	up  *blockUpload
+	ctx context.Context
+	mtx sync.Mutex
+
+	// err is set once the writer is closed or aborted
+	err error
+
+	// resumeOff is the number of bytes uploaded by a previous writer
+	resumeOff int64
 }
 
 // NewAzureBlobWriteCloser returns an io.WriteCloser that can be used to write
@@ -339,6 +686,17 @@ func NewAzureBlobWriteCloser(
 	creds azcore.TokenCredential,
 	openTimeout time.Duration,
 	ctx context.Context,
+) (*azWriter, error) {
+	return newAzureBlobWriter(ctx, blobURL, creds, &WriterOptions{})
+}
+
+// newAzureBlobWriter returns a writer which streams data to the blob at
+// blobURL, uploading blocks as configured by opts.
+func newAzureBlobWriter(
+	ctx context.Context,
+	blobURL string,
+	creds azcore.TokenCredential,
+	opts *WriterOptions,
 ) (*azWriter, error) {
 	if creds == nil {
 		return nil, errors.New("nil credentials")
@@ -348,6 +706,19 @@ func NewAzureBlobWriteCloser(
 		return nil, ErrInvalidBlobURL
 	}
 	u.Scheme = "https"
+	if q := u.Query(); q.Has(querySnapshot) || q.Has(queryVersionID) {
+		return nil, fmt.Errorf("%w, snapshots and versions are read-only", ErrInvalidBlobURL)
+	}
+	bs, err := opts.blockSize()
+	if err != nil {
+		return nil, err
+	}
+	commitOpts := azCommitOptions(opts, u.Path)
+	var stageOpts blockblob.StageBlockOptions
+	if opts.checksum {
+		// Blocks are verified in transit, and the blob as a whole by readers
+		stageOpts.TransactionalValidation = blob.TransferValidationTypeComputeCRC64()
+	}
 
 	// Initialize client
 	blobClient, err := blockblob.NewClient(u.String(), creds, nil)
@@ -355,41 +726,175 @@ func NewAzureBlobWriteCloser(
 		return nil, err
 	}
 
-	// Init
-	var sc azWriter
-	sc.blob = blobClient
-
-	r, w := io.Pipe()
-	go func() {
-		_, err := blobClient.UploadStream(ctx, r, nil)
-		if err != nil {
-			sc.mtx.Lock()
-			defer sc.mtx.Unlock()
-			sc.err = err
-			return
+	// Fail early rather than after the upload if the precondition does not
+	// hold. It is enforced again when the blob is committed.
+	if commitOpts.AccessConditions != nil {
+		if err := checkAzureWriteConditions(ctx, blobClient, opts); err != nil {
+			return nil, err
 		}
-	}()
-	sc.w = w
+	}
 
-	return &sc, nil
+	var sum hash.Hash
+	if opts.checksum {
+		sum = md5.New()
+	}
+	// Blocks are staged and committed by the writer, so that checksums,
+	// headers and retention are all set atomically with the data.
+	up, err := newBlockUpload(ctx, blobClient, u.String(), opts, bs, &stageOpts, commitOpts, sum)
+	if err != nil {
+		return nil, err
+	}
+	return &azWriter{up: up, ctx: ctx, resumeOff: up.off}, nil
+}
+
+// azCommitOptions returns the options for committing a blob at the provided
+// path, which set its properties and retention as configured by opts.
+func azCommitOptions(opts *WriterOptions, blobPath string) *blockblob.CommitBlockListOptions {
+	o := &blockblob.CommitBlockListOptions{
+		Metadata: opts.Metadata,
+		Tags:     opts.Tags,
+		HTTPHeaders: &blob.HTTPHeaders{
+			BlobContentType:     optionalString(opts.contentType(blobPath)),
+			BlobContentEncoding: optionalString(opts.ContentEncoding),
+			BlobCacheControl:    optionalString(opts.CacheControl),
+		},
+		AccessConditions: azWriteConditions(opts),
+	}
+	if opts.AccessTier != "" {
+		tier := blob.AccessTier(opts.AccessTier)
+		o.Tier = &tier
+	}
+	if opts.LegalHold {
+		o.LegalHold = &opts.LegalHold
+	}
+	if !opts.ImmutableUntil.IsZero() {
+		mode := immutabilityMode(opts)
+		o.ImmutabilityPolicyMode = &mode
+		o.ImmutabilityPolicyExpiryTime = &opts.ImmutableUntil
+	}
+	return o
 }
 
-// Write implements io.Writer
+// immutabilityMode returns the mode of the immutability policy set by opts.
+func immutabilityMode(opts *WriterOptions) blob.ImmutabilityPolicySetting {
+	if opts.LockImmutability {
+		return blob.ImmutabilityPolicySettingLocked
+	}
+	return blob.ImmutabilityPolicySettingUnlocked
+}
+
+// Write implements io.Writer. Write fails with the error of the upload as soon
+// as it has failed.
 func (sc *azWriter) Write(p []byte) (n int, err error) {
 	sc.mtx.Lock()
 	defer sc.mtx.Unlock()
-	return sc.w.Write(p)
+	if sc.err != nil {
+		return 0, sc.err
+	}
+	return sc.up.write(p)
 }
 
-// Close closes the underlying blob connection.
+// ReadFrom reads data from r until EOF and writes it to the blob. Data is read
+// straight into the buffers of the blocks which are staged.
+func (sc *azWriter) ReadFrom(r io.Reader) (int64, error) {
+	sc.mtx.Lock()
+	defer sc.mtx.Unlock()
+	if sc.err != nil {
+		return 0, sc.err
+	}
+	return sc.up.readFrom(r)
+}
+
+// Close completes the upload, and waits for the blob to be committed.
 func (sc *azWriter) Close() error {
 	sc.mtx.Lock()
 	defer sc.mtx.Unlock()
-	closeErr := sc.w.Close()
 	if sc.err != nil {
 		return sc.err
 	}
-	return closeErr
+	sc.err = os.ErrClosed
+	err := sc.up.commit(sc.ctx)
+	if bloberror.HasCode(err, bloberror.ConditionNotMet, bloberror.BlobAlreadyExists) {
+		err = fmt.Errorf("%w, %v", ErrPrecondition, err)
+	}
+	return err
+}
+
+// ResumeOffset returns the number of bytes which were uploaded by a previous
+// writer with the same journal. Writes continue at this offset.
+func (sc *azWriter) ResumeOffset() int64 {
+	return sc.resumeOff
+}
+
+// CloseWithError aborts the upload, and waits for blocks in flight to be
+// staged. Staged blocks are never committed, and are eventually garbage
+// collected by Azure unless the upload is resumed.
+func (sc *azWriter) CloseWithError(err error) error {
+	if err == nil {
+		err = ErrWriteAborted
+	}
+	// Failing the upload unblocks any pending Write which holds the lock
+	sc.up.fail(err)
+	sc.mtx.Lock()
+	defer sc.mtx.Unlock()
+	if sc.err != nil {
+		return nil
+	}
+	sc.err = err
+	return sc.up.abort(err)
+}
+
+// azWriteConditions returns the access conditions for committing a blob
+// written with the provided options, or nil if there are none.
+func azWriteConditions(opts *WriterOptions) *blob.AccessConditions {
+	var cond blob.ModifiedAccessConditions
+	switch {
+	case opts.IfNotExists:
+		etag := azcore.ETagAny
+		cond.IfNoneMatch = &etag
+	case opts.IfMatch != "":
+		etag := azcore.ETag(opts.IfMatch)
+		cond.IfMatch = &etag
+	default:
+		return nil
+	}
+	return &blob.AccessConditions{ModifiedAccessConditions: &cond}
+}
+
+// checkAzureWriteConditions returns ErrPrecondition if the blob does not
+// satisfy the preconditions of the provided options.
+func checkAzureWriteConditions(
+	ctx context.Context,
+	blobClient *blockblob.Client,
+	opts *WriterOptions,
+) error {
+	resp, err := blobClient.GetProperties(ctx, nil)
+	switch {
+	case err == nil && opts.IfNotExists:
+		return fmt.Errorf("%w, blob already exists", ErrPrecondition)
+	case bloberror.HasCode(err, bloberror.BlobNotFound) && opts.IfNotExists:
+		return nil
+	case bloberror.HasCode(err, bloberror.BlobNotFound):
+		return fmt.Errorf("%w, blob does not exist", ErrPrecondition)
+	case err != nil:
+		return err
+	}
+	var etag azcore.ETag
+	if resp.ETag != nil {
+		etag = *resp.ETag
+	}
+	if string(etag) != opts.IfMatch {
+		return fmt.Errorf("%w, etag %v does not match %v", ErrPrecondition, etag, opts.IfMatch)
+	}
+	return nil
+}
+
+// optionalString returns a pointer to s, or nil if s is empty.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
 }
 
 var blobPattern = regexp.MustCompile(`(https|abs)://([^/\.]+)(\.blob\.core\.windows\.net)/(.*)/(.*)`)
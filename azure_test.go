@@ -3,6 +3,7 @@ package remotefilez_test
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
@@ -223,4 +224,147 @@ func TestAzure(t *testing.T) {
 			})
 		}
 	}
+
+	t.Run("splits bypass disk cache", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		splits, err := ro.WithDiskCache(cacheDir, 1<<30).PlanSplits(ctx, absURL.String(), 1<<10, nil)
+		require.NoError(t, err)
+		require.Greater(t, len(splits), 1)
+		entries, err := os.ReadDir(cacheDir)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+
+	t.Run("object changed", func(t *testing.T) {
+		r, err := ro.OpenReaderCtx(ctx, absURL.String())
+		require.NoError(t, err)
+		defer r.Close()
+
+		// Overwrite the blob after it has been opened
+		w, err := ro.OpenWriterCtx(ctx, absURL.String())
+		require.NoError(t, err)
+		_, err = w.Write([]byte("overwritten"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		_, err = r.Read(make([]byte, 10))
+		require.ErrorIs(t, err, remotefilez.ErrObjectChanged)
+	})
+
+	t.Run("empty blob", func(t *testing.T) {
+		emptyURL := *absURL
+		emptyURL.Path += ".empty"
+		w, err := ro.OpenWriterCtx(ctx, emptyURL.String())
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		r, err := ro.OpenReaderCtx(ctx, emptyURL.String())
+		require.NoError(t, err)
+		defer r.Close()
+		sz, err := r.Size()
+		require.NoError(t, err)
+		require.Zero(t, sz)
+		_, err = r.Read(make([]byte, 10))
+		require.ErrorIs(t, err, io.EOF)
+		_, err = r.ReadAt(make([]byte, 10), 0)
+		require.ErrorIs(t, err, io.EOF)
+	})
+	t.Run("abort", func(t *testing.T) {
+		abortURL := *absURL
+		abortURL.Path += ".aborted"
+		w, err := ro.OpenWriterWithOptions(ctx, abortURL.String(), nil)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("partial"))
+		require.NoError(t, err)
+		require.NoError(t, w.CloseWithError(nil))
+		require.ErrorIs(t, w.Close(), remotefilez.ErrWriteAborted)
+
+		_, err = ro.Stat(ctx, abortURL.String())
+		require.Error(t, err)
+	})
+	t.Run("create only", func(t *testing.T) {
+		_, err := ro.OpenWriterWithOptions(ctx, absURL.String(), &remotefilez.WriterOptions{
+			IfNotExists: true,
+		})
+		require.ErrorIs(t, err, remotefilez.ErrPrecondition)
+		_, err = ro.OpenWriterWithOptions(ctx, absURL.String(), &remotefilez.WriterOptions{
+			IfMatch: `"0x0"`,
+		})
+		require.ErrorIs(t, err, remotefilez.ErrPrecondition)
+	})
+	t.Run("checksums", func(t *testing.T) {
+		sumURL := *absURL
+		sumURL.Path += ".md5"
+		cro := ro.WithChecksums(true)
+		w, err := cro.OpenWriterCtx(ctx, sumURL.String())
+		require.NoError(t, err)
+		_, err = w.Write([]byte("hello world"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		fi, err := cro.Stat(ctx, sumURL.String())
+		require.NoError(t, err)
+		want := md5.Sum([]byte("hello world"))
+		require.Equal(t, want[:], fi.ContentMD5)
+
+		r, err := cro.OpenReaderCtx(ctx, sumURL.String())
+		require.NoError(t, err)
+		defer r.Close()
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, "hello world", string(got))
+	})
+	t.Run("resume", func(t *testing.T) {
+		resumeURL := *absURL
+		resumeURL.Path += ".resumed"
+		opts := &remotefilez.WriterOptions{
+			BlockSize: 1 << 20,
+			Journal:   t.TempDir() + "/upload.journal",
+		}
+		want := make([]byte, 3<<19)
+		_, err := rand.Read(want)
+		require.NoError(t, err)
+
+		// Upload the first block, then fail
+		w, err := ro.OpenWriterWithOptions(ctx, resumeURL.String(), opts)
+		require.NoError(t, err)
+		require.Zero(t, w.(remotefilez.Resumer).ResumeOffset())
+		_, err = w.Write(want[:1<<20])
+		require.NoError(t, err)
+		require.NoError(t, w.CloseWithError(nil))
+
+		w, err = ro.OpenWriterWithOptions(ctx, resumeURL.String(), opts)
+		require.NoError(t, err)
+		off := w.(remotefilez.Resumer).ResumeOffset()
+		require.Equal(t, int64(1<<20), off)
+		_, err = w.Write(want[off:])
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		_, err = os.Stat(opts.Journal)
+		require.ErrorIs(t, err, os.ErrNotExist)
+
+		r, err := ro.OpenReaderCtx(ctx, resumeURL.String())
+		require.NoError(t, err)
+		defer r.Close()
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	})
+	t.Run("access tier", func(t *testing.T) {
+		tierURL := *absURL
+		tierURL.Path += ".cool"
+		w, err := ro.OpenWriterWithOptions(ctx, tierURL.String(), &remotefilez.WriterOptions{
+			AccessTier: "Cool",
+		})
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		fi, err := ro.Stat(ctx, tierURL.String())
+		require.NoError(t, err)
+		require.Equal(t, "Cool", fi.Extra["AccessTier"])
+
+		require.NoError(t, ro.SetTier(ctx, tierURL.String(), "Hot"))
+		fi, err = ro.Stat(ctx, tierURL.String())
+		require.NoError(t, err)
+		require.Equal(t, "Hot", fi.Extra["AccessTier"])
+	})
 }
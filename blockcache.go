@@ -0,0 +1,72 @@
+package remotefilez
+
+import (
+	"container/list"
+	"sync"
+)
+
+// blockKey identifies a block of a specific version of an object.
+type blockKey struct {
+	obj string
+	bs  int64
+	idx int64
+}
+
+type cacheEntry struct {
+	key blockKey
+	buf []byte
+}
+
+// blockCache is a size-bounded LRU cache of object blocks. It is safe for
+// concurrent use and is shared by all readers created by the same Opener.
+type blockCache struct {
+	mtx     sync.Mutex
+	max     int64
+	size    int64
+	ll      *list.List
+	entries map[blockKey]*list.Element
+}
+
+func newBlockCache(maxBytes int64) *blockCache {
+	return &blockCache{
+		max:     maxBytes,
+		ll:      list.New(),
+		entries: make(map[blockKey]*list.Element),
+	}
+}
+
+// get returns the cached block for the key, if any.
+func (c *blockCache) get(key blockKey) ([]byte, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	el, exists := c.entries[key]
+	if !exists {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*cacheEntry).buf, true
+}
+
+// add adds the block to the cache, evicting least recently used blocks until
+// the cache fits within its size limit. Blocks larger than the limit are not
+// cached.
+func (c *blockCache) add(key blockKey, buf []byte) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if int64(len(buf)) > c.max {
+		return
+	}
+	if el, exists := c.entries[key]; exists {
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.ll.PushFront(&cacheEntry{key: key, buf: buf})
+	c.size += int64(len(buf))
+	for c.size > c.max {
+		el := c.ll.Back()
+		e := el.Value.(*cacheEntry)
+		c.ll.Remove(el)
+		delete(c.entries, e.key)
+		c.size -= int64(len(e.buf))
+	}
+}
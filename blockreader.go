@@ -0,0 +1,297 @@
+package remotefilez
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Interface guards
+var _ ReaderAtSeekCloser = (*blockReader)(nil)
+var _ Stater = (*blockReader)(nil)
+var _ io.WriterTo = (*blockReader)(nil)
+var _ ContextReader = (*blockReader)(nil)
+var _ verifiable = (*blockReader)(nil)
+
+const defaultReadAheadBlockSize = 4 << 20
+
+// rangeReader is implemented by remote readers that can fetch an arbitrary
+// byte range with a dedicated request, independently of any stream state.
+// Implementations must be safe for concurrent use.
+type rangeReader interface {
+	readRange(ctx context.Context, p []byte, off int64) (int, error)
+	// objectKey returns a key which uniquely identifies the version of the
+	// object being read.
+	objectKey() string
+	Size() (int64, error)
+	Close() error
+}
+
+// block is a fixed-size chunk of the underlying object which is either being
+// fetched or has been fetched.
+type block struct {
+	done chan struct{}
+	buf  []byte
+	err  error
+}
+
+// blockReader reads an object in fixed-size blocks. While the caller consumes
+// one block, up to depth following blocks are fetched in the background.
+// Fetched blocks are added to the cache, if any, and blocks found in the cache
+// are never fetched.
+type blockReader struct {
+	src   rangeReader
+	bs    int64
+	depth int
+	cache *blockCache
+	obj   string
+
+	// ctx is cancelled when the reader is closed, aborting background fetches.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// verifier checks sequential reads against the stored checksum of the
+	// object, if any. Fetched blocks are not verified by src.
+	verifier *readVerifier
+
+	mtx    sync.Mutex
+	n      int64
+	off    int64
+	blocks map[int64]*block
+	closed bool
+}
+
+func newBlockReader(
+	src rangeReader,
+	blockSize int64,
+	depth int,
+	cache *blockCache,
+) (*blockReader, error) {
+	n, err := src.Size()
+	if err != nil {
+		return nil, err
+	}
+	if blockSize <= 0 {
+		blockSize = defaultReadAheadBlockSize
+	}
+	if depth < 0 {
+		depth = 0
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	br := &blockReader{
+		src:    src,
+		ctx:    ctx,
+		cancel: cancel,
+		bs:     blockSize,
+		depth:  depth,
+		cache:  cache,
+		obj:    src.objectKey(),
+		n:      n,
+		blocks: make(map[int64]*block),
+	}
+	br.verifier = br.newVerifier()
+	return br, nil
+}
+
+// newVerifier implements verifiable, verifying the object read by src.
+func (br *blockReader) newVerifier() *readVerifier {
+	if v, ok := br.src.(verifiable); ok {
+		return v.newVerifier()
+	}
+	return nil
+}
+
+// Read implements io.Reader.
+func (br *blockReader) Read(p []byte) (int, error) {
+	return br.ReadCtx(context.Background(), p)
+}
+
+// ReadCtx is like Read, but stops waiting for data once ctx is done.
+func (br *blockReader) ReadCtx(ctx context.Context, p []byte) (int, error) {
+	br.mtx.Lock()
+	defer br.mtx.Unlock()
+	n, err := br.readAt(ctx, p, br.off)
+	br.verifier.add(p[:n], br.off)
+	br.off += int64(n)
+	if err == nil {
+		err = br.verifier.verify()
+	}
+	return n, err
+}
+
+// ReadAt implements io.ReaderAt. It does not affect the offset used by Read.
+func (br *blockReader) ReadAt(p []byte, off int64) (int, error) {
+	return br.ReadAtCtx(context.Background(), p, off)
+}
+
+// ReadAtCtx is like ReadAt, but stops waiting for data once ctx is done.
+func (br *blockReader) ReadAtCtx(ctx context.Context, p []byte, off int64) (int, error) {
+	br.mtx.Lock()
+	defer br.mtx.Unlock()
+	var n int
+	for n < len(p) {
+		m, err := br.readAt(ctx, p[n:], off+int64(n))
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// readAt reads from at most one block. You must hold br.mtx before calling
+// this function.
+func (br *blockReader) readAt(ctx context.Context, p []byte, off int64) (int, error) {
+	if br.closed {
+		return 0, errors.New("read on closed reader")
+	}
+	if off < 0 {
+		return 0, errors.New("offset out of bounds")
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if off >= br.n {
+		return 0, io.EOF
+	}
+	idx := off / br.bs
+	b := br.fetch(idx)
+	for i := int64(1); i <= int64(br.depth); i++ {
+		br.fetch(idx + i)
+	}
+	br.evict(idx)
+
+	// Background fetches never take br.mtx, so it is safe to wait here. If
+	// ctx is done, the fetch carries on in the background.
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	if b.err != nil {
+		delete(br.blocks, idx)
+		return 0, b.err
+	}
+	n := copy(p, b.buf[off-idx*br.bs:])
+	return n, nil
+}
+
+// fetch returns the block with the provided index, starting a background
+// download if it is not already present. You must hold br.mtx before calling
+// this function.
+func (br *blockReader) fetch(idx int64) *block {
+	if b, exists := br.blocks[idx]; exists {
+		return b
+	}
+	b := &block{done: make(chan struct{})}
+	off := idx * br.bs
+	if off >= br.n {
+		close(b.done)
+		return b
+	}
+	br.blocks[idx] = b
+	key := blockKey{obj: br.obj, bs: br.bs, idx: idx}
+	if br.cache != nil {
+		if buf, exists := br.cache.get(key); exists {
+			b.buf = buf
+			close(b.done)
+			return b
+		}
+	}
+	go func() {
+		defer close(b.done)
+		buf := make([]byte, min(br.bs, br.n-off))
+		m, err := br.src.readRange(br.ctx, buf, off)
+		b.buf = buf[:m]
+		if err == io.EOF && m > 0 {
+			err = nil
+		}
+		b.err = err
+		if err == nil && br.cache != nil {
+			br.cache.add(key, b.buf)
+		}
+	}()
+	return b
+}
+
+// evict drops blocks outside the read-ahead window starting at idx. You must
+// hold br.mtx before calling this function.
+func (br *blockReader) evict(idx int64) {
+	for i := range br.blocks {
+		if i < idx || i > idx+int64(br.depth) {
+			delete(br.blocks, i)
+		}
+	}
+}
+
+// WriteTo writes the remainder of the object to w, one block at a time.
+func (br *blockReader) WriteTo(w io.Writer) (int64, error) {
+	br.mtx.Lock()
+	defer br.mtx.Unlock()
+	var written int64
+	buf := make([]byte, br.bs)
+	for br.off < br.n {
+		n, err := br.readAt(br.ctx, buf, br.off)
+		if err != nil {
+			return written, err
+		}
+		br.verifier.add(buf[:n], br.off)
+		m, err := w.Write(buf[:n])
+		br.off += int64(m)
+		written += int64(m)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, br.verifier.verify()
+}
+
+// Seek implements io.Seeker. Seeking is free; blocks are fetched on the next
+// read.
+func (br *blockReader) Seek(offset int64, whence int) (int64, error) {
+	return br.SeekCtx(context.Background(), offset, whence)
+}
+
+// SeekCtx is equivalent to Seek, since seeking never blocks.
+func (br *blockReader) SeekCtx(_ context.Context, offset int64, whence int) (int64, error) {
+	br.mtx.Lock()
+	defer br.mtx.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += br.off
+	case io.SeekEnd:
+		offset += br.n
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("offset out of bounds")
+	}
+	br.off = offset
+	return br.off, nil
+}
+
+// Size returns the size of the underlying object.
+func (br *blockReader) Size() (int64, error) {
+	return br.n, nil
+}
+
+// FileInfo returns information about the underlying object.
+func (br *blockReader) FileInfo() (FileInfo, error) {
+	if s, ok := br.src.(Stater); ok {
+		return s.FileInfo()
+	}
+	return FileInfo{}, ErrNotImplemented
+}
+
+// Close drops all prefetched blocks and closes the underlying reader.
+func (br *blockReader) Close() error {
+	br.mtx.Lock()
+	defer br.mtx.Unlock()
+	br.closed = true
+	br.blocks = nil
+	br.cancel()
+	return br.src.Close()
+}
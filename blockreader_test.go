@@ -0,0 +1,148 @@
+package remotefilez
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memRangeReader is an in-memory rangeReader which counts requests.
+type memRangeReader struct {
+	data  []byte
+	calls int32
+}
+
+func (m *memRangeReader) readRange(ctx context.Context, p []byte, off int64) (int, error) {
+	atomic.AddInt32(&m.calls, 1)
+	return bytes.NewReader(m.data).ReadAt(p, off)
+}
+
+func (m *memRangeReader) objectKey() string {
+	return "mem"
+}
+
+func (m *memRangeReader) Size() (int64, error) {
+	return int64(len(m.data)), nil
+}
+
+func (m *memRangeReader) Close() error {
+	return nil
+}
+
+// blockingRangeReader blocks all reads until unblocked or ctx is done.
+type blockingRangeReader struct {
+	memRangeReader
+	unblock chan struct{}
+}
+
+func (m *blockingRangeReader) readRange(ctx context.Context, p []byte, off int64) (int, error) {
+	select {
+	case <-m.unblock:
+		return m.memRangeReader.readRange(ctx, p, off)
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func TestBlockReader(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	t.Run("sequential", func(t *testing.T) {
+		src := &memRangeReader{data: data}
+		br, err := newBlockReader(src, 64, 3, nil)
+		require.NoError(t, err)
+		got, err := io.ReadAll(br)
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+		require.EqualValues(t, 16, atomic.LoadInt32(&src.calls))
+	})
+
+	t.Run("seek and readat", func(t *testing.T) {
+		src := &memRangeReader{data: data}
+		br, err := newBlockReader(src, 64, 2, nil)
+		require.NoError(t, err)
+
+		off, err := br.Seek(-10, io.SeekEnd)
+		require.NoError(t, err)
+		require.EqualValues(t, 990, off)
+		got, err := io.ReadAll(br)
+		require.NoError(t, err)
+		require.Equal(t, data[990:], got)
+
+		p := make([]byte, 200)
+		n, err := br.ReadAt(p, 100)
+		require.NoError(t, err)
+		require.Equal(t, 200, n)
+		require.Equal(t, data[100:300], p)
+
+		n, err = br.ReadAt(p, 900)
+		require.ErrorIs(t, err, io.EOF)
+		require.Equal(t, 100, n)
+		require.Equal(t, data[900:], p[:n])
+		require.NoError(t, br.Close())
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		src := &memRangeReader{data: data}
+		cache := newBlockCache(256)
+		for i := 0; i < 2; i++ {
+			br, err := newBlockReader(src, 64, 0, cache)
+			require.NoError(t, err)
+			p := make([]byte, 100)
+			_, err = br.ReadAt(p, 900)
+			require.NoError(t, err)
+			require.Equal(t, data[900:], p)
+			require.NoError(t, br.Close())
+		}
+		// Blocks 14 and 15 are fetched once and then served from the cache
+		require.EqualValues(t, 2, atomic.LoadInt32(&src.calls))
+
+		// Reading the first 320 bytes evicts the tail blocks
+		br, err := newBlockReader(src, 64, 0, cache)
+		require.NoError(t, err)
+		_, err = br.ReadAt(make([]byte, 320), 0)
+		require.NoError(t, err)
+		_, err = br.ReadAt(make([]byte, 100), 900)
+		require.NoError(t, err)
+		require.EqualValues(t, 9, atomic.LoadInt32(&src.calls))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		br, err := newBlockReader(&memRangeReader{}, 64, 2, nil)
+		require.NoError(t, err)
+		_, err = br.Read(make([]byte, 10))
+		require.ErrorIs(t, err, io.EOF)
+		_, err = br.ReadAt(make([]byte, 10), 0)
+		require.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		src := &blockingRangeReader{
+			memRangeReader: memRangeReader{data: data},
+			unblock:        make(chan struct{}),
+		}
+		br, err := newBlockReader(src, 64, 2, nil)
+		require.NoError(t, err)
+		defer br.Close()
+
+		// Cancelled reads return immediately
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = br.ReadCtx(ctx, make([]byte, 10))
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// Later reads are not affected by the context of earlier reads
+		close(src.unblock)
+		p := make([]byte, 10)
+		_, err = br.ReadCtx(context.Background(), p)
+		require.NoError(t, err)
+		require.Equal(t, data[:10], p)
+	})
+}
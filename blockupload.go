@@ -0,0 +1,314 @@
+package remotefilez
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"hash"
+	"io"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+)
+
+// Interface guards
+var _ blockStager = (*blockblob.Client)(nil)
+
+// blockStager is the part of a block blob client used by blockUpload.
+type blockStager interface {
+	StageBlock(
+		ctx context.Context,
+		base64BlockID string,
+		body io.ReadSeekCloser,
+		o *blockblob.StageBlockOptions,
+	) (blockblob.StageBlockResponse, error)
+	CommitBlockList(
+		ctx context.Context,
+		base64BlockIDs []string,
+		o *blockblob.CommitBlockListOptions,
+	) (blockblob.CommitBlockListResponse, error)
+	GetBlockList(
+		ctx context.Context,
+		listType blockblob.BlockListType,
+		o *blockblob.GetBlockListOptions,
+	) (blockblob.GetBlockListResponse, error)
+}
+
+// blockUpload is an upload which stages blocks itself, recording each staged
+// block in a journal if it is resumable, and commits them once all data is
+// written. Data is buffered one block at a time, and full blocks are staged in
+// the background while the next one is filled.
+type blockUpload struct {
+	blob        blockStager
+	journal     *uploadJournal
+	bs          int64
+	concurrency int
+	stageOpts   *blockblob.StageBlockOptions
+	commitOpts  *blockblob.CommitBlockListOptions
+
+	// ctx is used to stage blocks in the background
+	ctx context.Context
+
+	// ids of the blocks staged so far, and the number of bytes in them
+	ids []string
+	off int64
+	md5 hash.Hash
+
+	// buf is the block being filled, with n bytes of data. Buffers are
+	// allocated on demand, and returned to bufs once their block is staged,
+	// which limits the number of blocks in flight.
+	buf   []byte
+	n     int
+	bufs  chan []byte
+	nbufs int
+
+	// wg tracks blocks in flight. The first failure is stored in err, and
+	// closes failed.
+	wg     sync.WaitGroup
+	errMtx sync.Mutex
+	err    error
+	failed chan struct{}
+}
+
+// newBlockUpload opens the journal of the upload to the blob, and resumes
+// after the blocks staged by a previous upload which are still available.
+// blockSize is ignored if the journal records the block size of a previous
+// upload.
+func newBlockUpload(
+	ctx context.Context,
+	blobClient blockStager,
+	blobURL string,
+	opts *WriterOptions,
+	blockSize int64,
+	stageOpts *blockblob.StageBlockOptions,
+	commitOpts *blockblob.CommitBlockListOptions,
+	sum hash.Hash,
+) (*blockUpload, error) {
+	j, entries, err := openUploadJournal(opts.Journal, blobURL, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	up := &blockUpload{
+		blob:        blobClient,
+		journal:     j,
+		bs:          j.hdr.BlockSize,
+		concurrency: opts.concurrency(j.hdr.BlockSize),
+		stageOpts:   stageOpts,
+		commitOpts:  commitOpts,
+		ctx:         ctx,
+		md5:         sum,
+		bufs:        make(chan []byte, opts.concurrency(j.hdr.BlockSize)),
+		failed:      make(chan struct{}),
+	}
+	if len(entries) > 0 {
+		if err := up.resume(ctx, entries); err != nil {
+			j.close()
+			return nil, err
+		}
+	}
+	return up, nil
+}
+
+// resume skips the blocks staged by a previous upload. Uncommitted blocks are
+// discarded by Azure after a week, so only blocks which are still staged are
+// skipped.
+func (up *blockUpload) resume(ctx context.Context, entries []journalEntry) error {
+	resp, err := up.blob.GetBlockList(ctx, blockblob.BlockListTypeUncommitted, nil)
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return err
+	}
+	staged := make(map[string]int64)
+	for _, b := range resp.UncommittedBlocks {
+		if b.Name != nil && b.Size != nil {
+			staged[*b.Name] = *b.Size
+		}
+	}
+	var n int
+	for ; n < len(entries); n++ {
+		id := up.journal.blockID(entries[n].Index)
+		if size, ok := staged[id]; !ok || size != entries[n].Size {
+			break
+		}
+		if up.md5 != nil && len(entries[n].MD5) == 0 {
+			// Written without checksums, the checksum cannot be resumed
+			break
+		}
+		up.ids = append(up.ids, id)
+		up.off += entries[n].Size
+	}
+	if n > 0 && up.md5 != nil {
+		state := entries[n-1].MD5
+		if err := up.md5.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+			return err
+		}
+	}
+	if n < len(entries) {
+		return up.journal.rewrite(entries[:n])
+	}
+	return nil
+}
+
+// write buffers p, and stages each block which is filled.
+func (up *blockUpload) write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		if err := up.next(); err != nil {
+			return written, err
+		}
+		m := copy(up.buf[up.n:], p)
+		up.n += m
+		written += m
+		p = p[m:]
+		if up.n == len(up.buf) {
+			if err := up.stage(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// readFrom reads from r until EOF directly into block buffers, and stages
+// each block which is filled.
+func (up *blockUpload) readFrom(r io.Reader) (int64, error) {
+	var read int64
+	for {
+		if err := up.next(); err != nil {
+			return read, err
+		}
+		m, err := io.ReadFull(r, up.buf[up.n:])
+		up.n += m
+		read += int64(m)
+		if up.n == len(up.buf) {
+			if err := up.stage(); err != nil {
+				return read, err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return read, nil
+		}
+		if err != nil {
+			return read, err
+		}
+	}
+}
+
+// next makes sure that there is a block to fill, waiting for a buffer if all
+// of them are in flight. It fails as soon as the upload has failed.
+func (up *blockUpload) next() error {
+	if err := up.uploadErr(); err != nil || up.buf != nil {
+		return err
+	}
+	if up.nbufs < up.concurrency {
+		select {
+		case up.buf = <-up.bufs:
+		default:
+			up.nbufs++
+			up.buf = make([]byte, up.bs)
+		}
+		return nil
+	}
+	select {
+	case up.buf = <-up.bufs:
+		return up.uploadErr()
+	case <-up.failed:
+		return up.uploadErr()
+	}
+}
+
+// stage stages the current block in the background.
+func (up *blockUpload) stage() error {
+	buf, n := up.buf, up.n
+	up.buf, up.n = nil, 0
+	up.errMtx.Lock()
+	if up.err != nil {
+		up.errMtx.Unlock()
+		up.bufs <- buf
+		return up.err
+	}
+	up.wg.Add(1)
+	up.errMtx.Unlock()
+
+	idx := len(up.ids)
+	e := journalEntry{Index: idx, Size: int64(n)}
+	if up.md5 != nil {
+		up.md5.Write(buf[:n])
+		e.MD5, _ = up.md5.(encoding.BinaryMarshaler).MarshalBinary()
+	}
+	id := up.journal.blockID(idx)
+	up.ids = append(up.ids, id)
+	up.off += int64(n)
+	go func() {
+		defer up.wg.Done()
+		defer func() { up.bufs <- buf }()
+		// Blocks in flight when the upload fails are still staged and
+		// journaled, so that a resumed upload can skip them.
+		body := streaming.NopCloser(bytes.NewReader(buf[:n]))
+		_, err := up.blob.StageBlock(up.ctx, id, body, up.stageOpts)
+		if err == nil {
+			err = up.journal.append(e)
+		}
+		if err != nil {
+			up.fail(err)
+		}
+	}()
+	return nil
+}
+
+// fail fails the upload with err, unless it has already failed.
+func (up *blockUpload) fail(err error) {
+	up.errMtx.Lock()
+	defer up.errMtx.Unlock()
+	if up.err == nil {
+		up.err = err
+		close(up.failed)
+	}
+}
+
+// uploadErr returns the error the upload failed with, if any.
+func (up *blockUpload) uploadErr() error {
+	up.errMtx.Lock()
+	defer up.errMtx.Unlock()
+	return up.err
+}
+
+// commit stages the last block, and commits all blocks once they are staged.
+// The journal is kept if the upload fails, so that it can be resumed.
+func (up *blockUpload) commit(ctx context.Context) error {
+	if up.n > 0 {
+		up.stage()
+	}
+	up.wg.Wait()
+	err := up.uploadErr()
+	if err == nil {
+		var commitOpts blockblob.CommitBlockListOptions
+		if up.commitOpts != nil {
+			commitOpts = *up.commitOpts
+		}
+		if up.md5 != nil {
+			var headers blob.HTTPHeaders
+			if commitOpts.HTTPHeaders != nil {
+				headers = *commitOpts.HTTPHeaders
+			}
+			headers.BlobContentMD5 = up.md5.Sum(nil)
+			commitOpts.HTTPHeaders = &headers
+		}
+		_, err = up.blob.CommitBlockList(ctx, up.ids, &commitOpts)
+	}
+	if err != nil {
+		up.journal.close()
+		return err
+	}
+	return up.journal.remove()
+}
+
+// abort fails the upload with err, and waits for blocks in flight to be
+// staged. The journal is kept, so that the upload can be resumed.
+func (up *blockUpload) abort(err error) error {
+	up.fail(err)
+	up.wg.Wait()
+	return up.journal.close()
+}
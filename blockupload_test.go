@@ -0,0 +1,187 @@
+package remotefilez
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/stretchr/testify/require"
+)
+
+// memStager is an in-memory block blob.
+type memStager struct {
+	// delay is the duration of each StageBlock call. If release is set,
+	// StageBlock also waits for it to be closed.
+	delay   time.Duration
+	release chan struct{}
+
+	// err fails each StageBlock call
+	err error
+
+	mtx        sync.Mutex
+	sizes      []int
+	staged     map[string][]byte
+	committed  []byte
+	commitOpts *blockblob.CommitBlockListOptions
+}
+
+func (s *memStager) StageBlock(
+	ctx context.Context,
+	base64BlockID string,
+	body io.ReadSeekCloser,
+	_ *blockblob.StageBlockOptions,
+) (blockblob.StageBlockResponse, error) {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return blockblob.StageBlockResponse{}, ctx.Err()
+	}
+	if s.release != nil {
+		<-s.release
+	}
+	if s.err != nil {
+		return blockblob.StageBlockResponse{}, s.err
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return blockblob.StageBlockResponse{}, err
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.staged == nil {
+		s.staged = make(map[string][]byte)
+	}
+	s.staged[base64BlockID] = data
+	s.sizes = append(s.sizes, len(data))
+	return blockblob.StageBlockResponse{}, nil
+}
+
+func (s *memStager) CommitBlockList(
+	_ context.Context,
+	base64BlockIDs []string,
+	o *blockblob.CommitBlockListOptions,
+) (blockblob.CommitBlockListResponse, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	var data []byte
+	for _, id := range base64BlockIDs {
+		b, ok := s.staged[id]
+		if !ok {
+			return blockblob.CommitBlockListResponse{}, fmt.Errorf("block %v is not staged", id)
+		}
+		data = append(data, b...)
+	}
+	s.staged = nil
+	s.committed = data
+	s.commitOpts = o
+	return blockblob.CommitBlockListResponse{}, nil
+}
+
+func (s *memStager) GetBlockList(
+	context.Context,
+	blockblob.BlockListType,
+	*blockblob.GetBlockListOptions,
+) (blockblob.GetBlockListResponse, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	var resp blockblob.GetBlockListResponse
+	for id, data := range s.staged {
+		id, size := id, int64(len(data))
+		resp.UncommittedBlocks = append(resp.UncommittedBlocks, &blockblob.Block{Name: &id, Size: &size})
+	}
+	return resp, nil
+}
+
+// runUpload uploads the data read from r, and aborts the upload if the read
+// fails.
+func runUpload(ctx context.Context, up *blockUpload, r io.Reader) error {
+	if _, err := up.readFrom(r); err != nil {
+		up.abort(err)
+		return err
+	}
+	return up.commit(ctx)
+}
+
+func TestBlockUpload(t *testing.T) {
+	ctx := context.Background()
+	const url = "https://acct.blob.core.windows.net/c/blob"
+	data := []byte("hello world, hello blocks")
+
+	t.Run("in-flight blocks are journaled", func(t *testing.T) {
+		opts := &WriterOptions{Journal: filepath.Join(t.TempDir(), "journal"), Concurrency: 4}
+		s := &memStager{delay: 20 * time.Millisecond}
+		up, err := newBlockUpload(ctx, s, url, opts, 4, nil, nil, nil)
+		require.NoError(t, err)
+
+		// The read fails while the first two blocks are being staged
+		readErr := errors.New("read failed")
+		r := io.MultiReader(bytes.NewReader(data[:10]), iotest.ErrReader(readErr))
+		require.ErrorIs(t, runUpload(ctx, up, r), readErr)
+		_, entries, err := openUploadJournal(opts.Journal, url, 4)
+		require.NoError(t, err)
+		require.Equal(t, []journalEntry{{Index: 0, Size: 4}, {Index: 1, Size: 4}}, entries)
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		opts := &WriterOptions{Journal: filepath.Join(t.TempDir(), "journal")}
+		s := &memStager{}
+		up, err := newBlockUpload(ctx, s, url, opts, 4, nil, nil, md5.New())
+		require.NoError(t, err)
+		readErr := errors.New("read failed")
+		r := io.MultiReader(bytes.NewReader(data[:14]), iotest.ErrReader(readErr))
+		require.ErrorIs(t, runUpload(ctx, up, r), readErr)
+
+		// Block 2 was journaled, but has since been discarded by Azure
+		s.mtx.Lock()
+		delete(s.staged, up.journal.blockID(2))
+		s.mtx.Unlock()
+
+		up, err = newBlockUpload(ctx, s, url, opts, 4, nil, nil, md5.New())
+		require.NoError(t, err)
+		require.Equal(t, int64(8), up.off)
+		require.Len(t, up.ids, 2)
+		require.NoError(t, runUpload(ctx, up, bytes.NewReader(data[up.off:])))
+		require.Equal(t, data, s.committed)
+		sum := md5.Sum(data)
+		require.Equal(t, sum[:], s.commitOpts.HTTPHeaders.BlobContentMD5)
+		require.NoFileExists(t, opts.Journal)
+	})
+	t.Run("without journal", func(t *testing.T) {
+		s := &memStager{}
+		up, err := newBlockUpload(ctx, s, url, &WriterOptions{}, 4, nil, nil, md5.New())
+		require.NoError(t, err)
+		require.NoError(t, runUpload(ctx, up, bytes.NewReader(data)))
+		require.Equal(t, data, s.committed)
+		sum := md5.Sum(data)
+		require.Equal(t, sum[:], s.commitOpts.HTTPHeaders.BlobContentMD5)
+	})
+	t.Run("retention is set on commit", func(t *testing.T) {
+		s := &memStager{}
+		until := time.Now().Add(time.Hour)
+		opts := &WriterOptions{
+			AccessTier:     "Archive",
+			LegalHold:      true,
+			ImmutableUntil: until,
+			ContentType:    "text/plain",
+		}
+		up, err := newBlockUpload(ctx, s, url, opts, 4, nil, azCommitOptions(opts, "/c/blob"), md5.New())
+		require.NoError(t, err)
+		require.NoError(t, runUpload(ctx, up, bytes.NewReader(data)))
+		require.Equal(t, blob.AccessTierArchive, *s.commitOpts.Tier)
+		require.Equal(t, "text/plain", *s.commitOpts.HTTPHeaders.BlobContentType)
+		require.NotEmpty(t, s.commitOpts.HTTPHeaders.BlobContentMD5)
+		require.True(t, *s.commitOpts.LegalHold)
+		require.Equal(t, until, *s.commitOpts.ImmutabilityPolicyExpiryTime)
+		require.Equal(t, blob.ImmutabilityPolicySettingUnlocked, *s.commitOpts.ImmutabilityPolicyMode)
+	})
+}
@@ -0,0 +1,137 @@
+package remotefilez
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Interface guards
+var _ ReaderAtSeekCloser = (*sectionFile)(nil)
+var _ Stater = (*sectionFile)(nil)
+
+// ByteRange is a range of bytes within a file.
+type ByteRange struct {
+	Offset int64
+	// Length is the number of bytes in the range. A negative length means
+	// the range extends to the end of the file.
+	Length int64
+}
+
+// fullRange spans an entire file.
+var fullRange = ByteRange{Offset: 0, Length: -1}
+
+// OpenRange returns a handle to n bytes of the file at the provided URL,
+// starting at offset off. Offsets and Size of the returned handle are relative
+// to the range, and remote files are only ever read within the range. A
+// negative n means the range extends to the end of the file.
+//
+// Opening a URL with a fragment such as #bytes=1000-1999 (inclusive, like an
+// HTTP Range header) is equivalent to calling OpenRange.
+func (ro *Opener) OpenRange(
+	ctx context.Context,
+	fileURL string,
+	off, n int64,
+) (ReaderAtSeekCloser, error) {
+	u, err := parseFileURL(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Fragment != "" {
+		return nil, fmt.Errorf("%w, URL already has a range fragment", ErrInvalidRange)
+	}
+	if off < 0 {
+		return nil, fmt.Errorf("%w, negative offset", ErrInvalidRange)
+	}
+	return ro.openReader(ctx, u, ByteRange{Offset: off, Length: n})
+}
+
+// parseByteRange parses a URL fragment on the form bytes=<first>-<last>, where
+// <last> is inclusive and may be omitted to read until the end of the file.
+func parseByteRange(fragment string) (ByteRange, error) {
+	if !strings.HasPrefix(fragment, "bytes=") {
+		return ByteRange{}, fmt.Errorf("%w %q", ErrInvalidRange, fragment)
+	}
+	spec := strings.TrimPrefix(fragment, "bytes=")
+	firstStr, lastStr, ok := strings.Cut(spec, "-")
+	if !ok {
+		return ByteRange{}, fmt.Errorf("%w %q", ErrInvalidRange, fragment)
+	}
+	first, err := strconv.ParseInt(firstStr, 10, 64)
+	if err != nil || first < 0 {
+		return ByteRange{}, fmt.Errorf("%w %q", ErrInvalidRange, fragment)
+	}
+	if lastStr == "" {
+		return ByteRange{Offset: first, Length: -1}, nil
+	}
+	last, err := strconv.ParseInt(lastStr, 10, 64)
+	if err != nil || last < first {
+		return ByteRange{}, fmt.Errorf("%w %q", ErrInvalidRange, fragment)
+	}
+	return ByteRange{Offset: first, Length: last - first + 1}, nil
+}
+
+// clamp returns the absolute offset and length of the range within a file of
+// the provided size.
+func (r ByteRange) clamp(size int64) (off, n int64, err error) {
+	if r.Offset > size {
+		return 0, 0, fmt.Errorf("%w, offset %v is beyond the file size %v",
+			ErrInvalidRange, r.Offset, size)
+	}
+	n = size - r.Offset
+	if r.Length >= 0 {
+		n = min(n, r.Length)
+	}
+	return r.Offset, n, nil
+}
+
+// sectionFile is a range of a local file.
+type sectionFile struct {
+	f  *os.File
+	sr *io.SectionReader
+}
+
+func newSectionFile(f *os.File, rng ByteRange) (*sectionFile, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	off, n, err := rng.clamp(fi.Size())
+	if err != nil {
+		return nil, err
+	}
+	return &sectionFile{f: f, sr: io.NewSectionReader(f, off, n)}, nil
+}
+
+func (f *sectionFile) Read(p []byte) (int, error) {
+	return f.sr.Read(p)
+}
+
+func (f *sectionFile) ReadAt(p []byte, off int64) (int, error) {
+	return f.sr.ReadAt(p, off)
+}
+
+func (f *sectionFile) Seek(offset int64, whence int) (int64, error) {
+	return f.sr.Seek(offset, whence)
+}
+
+// Size returns the size of the range.
+func (f *sectionFile) Size() (int64, error) {
+	return f.sr.Size(), nil
+}
+
+// FileInfo returns information about the underlying file.
+func (f *sectionFile) FileInfo() (FileInfo, error) {
+	fi, err := f.f.Stat()
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return localFileInfo(f.f.Name(), fi)
+}
+
+func (f *sectionFile) Close() error {
+	return f.f.Close()
+}
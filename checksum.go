@@ -0,0 +1,64 @@
+package remotefilez
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"hash"
+)
+
+// verifiable is implemented by readers of files which may be verified against
+// a stored checksum.
+type verifiable interface {
+	// newVerifier returns a verifier for sequential reads of the whole file,
+	// or nil if the file is not verified.
+	newVerifier() *readVerifier
+}
+
+// readVerifier verifies data read sequentially from the start of a file
+// against the stored MD5 of the file. A nil readVerifier verifies nothing.
+type readVerifier struct {
+	// md5 is the running checksum of the data up to offset hashed. It is nil
+	// once verification has been disabled or completed.
+	md5    hash.Hash
+	hashed int64
+	n      int64
+	want   []byte
+}
+
+// newReadVerifier returns a verifier for a file of n bytes with the provided
+// stored MD5, or nil if the file has no stored MD5.
+func newReadVerifier(want []byte, n int64) *readVerifier {
+	if len(want) != md5.Size {
+		return nil
+	}
+	return &readVerifier{md5: md5.New(), n: n, want: want}
+}
+
+// add adds p, which was read at offset off, to the checksum. Reads which are
+// not contiguous with the checksummed data disable verification.
+func (v *readVerifier) add(p []byte, off int64) {
+	if v == nil || v.md5 == nil {
+		return
+	}
+	if off != v.hashed {
+		v.md5 = nil
+		return
+	}
+	v.md5.Write(p)
+	v.hashed += int64(len(p))
+}
+
+// verify compares the checksum to the stored MD5 once the whole file has been
+// read, and returns ErrChecksumMismatch if they differ.
+func (v *readVerifier) verify() error {
+	if v == nil || v.md5 == nil || v.hashed != v.n {
+		return nil
+	}
+	sum := v.md5.Sum(nil)
+	v.md5 = nil
+	if !bytes.Equal(sum, v.want) {
+		return fmt.Errorf("%w, got md5 %x, want %x", ErrChecksumMismatch, sum, v.want)
+	}
+	return nil
+}
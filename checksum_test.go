@@ -0,0 +1,125 @@
+package remotefilez
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadVerifier(t *testing.T) {
+	data := []byte("hello world")
+	sum := md5.Sum(data)
+
+	v := newReadVerifier(sum[:], int64(len(data)))
+	v.add(data[:5], 0)
+	require.NoError(t, v.verify())
+	v.add(data[5:], 5)
+	require.NoError(t, v.verify())
+
+	v = newReadVerifier(sum[:], int64(len(data)))
+	v.add([]byte("jello world"), 0)
+	require.ErrorIs(t, v.verify(), ErrChecksumMismatch)
+
+	// Non-contiguous reads are not verified
+	v = newReadVerifier(sum[:], int64(len(data)))
+	v.add([]byte("jello"), 0)
+	v.add(data[6:], 6)
+	require.NoError(t, v.verify())
+
+	require.Nil(t, newReadVerifier(nil, int64(len(data))))
+}
+
+func TestChecksumVerification(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	good := md5.Sum(data)
+	bad := md5.Sum([]byte("other data"))
+	newReader := func(sum []byte) *azReader {
+		r := &azReader{
+			blob:   &flakyBlob{data: data},
+			n:      int64(len(data)),
+			retry:  defaultRetryPolicy,
+			info:   FileInfo{ContentMD5: sum},
+			verify: true,
+		}
+		r.verifier = r.newVerifier()
+		return r
+	}
+
+	for _, tc := range []struct {
+		name string
+		read func(r *azReader) ([]byte, error)
+	}{
+		{"read", func(r *azReader) ([]byte, error) {
+			return io.ReadAll(r)
+		}},
+		{"write to", func(r *azReader) ([]byte, error) {
+			var buf bytes.Buffer
+			_, err := r.WriteTo(&buf)
+			return buf.Bytes(), err
+		}},
+		{"read ahead", func(r *azReader) ([]byte, error) {
+			br, err := newBlockReader(r, 64, 2, nil)
+			require.NoError(t, err)
+			defer br.Close()
+			return io.ReadAll(br)
+		}},
+		{"block cache write to", func(r *azReader) ([]byte, error) {
+			br, err := newBlockReader(r, 64, 0, newBlockCache(1<<20))
+			require.NoError(t, err)
+			defer br.Close()
+			var buf bytes.Buffer
+			_, err = br.WriteTo(&buf)
+			return buf.Bytes(), err
+		}},
+		{"download", func(r *azReader) ([]byte, error) {
+			dst := t.TempDir() + "/out"
+			var ro Opener
+			if err := ro.download(context.Background(), r, dst, &DownloadOptions{ChunkSize: 64}); err != nil {
+				require.NoFileExists(t, dst)
+				return nil, err
+			}
+			return os.ReadFile(dst)
+		}},
+		{"download with read ahead", func(r *azReader) ([]byte, error) {
+			br, err := newBlockReader(r, 64, 2, nil)
+			require.NoError(t, err)
+			defer br.Close()
+			dst := t.TempDir() + "/out"
+			var ro Opener
+			if err := ro.download(context.Background(), br, dst, nil); err != nil {
+				return nil, err
+			}
+			return os.ReadFile(dst)
+		}},
+		{"disk cache", func(r *azReader) ([]byte, error) {
+			dir := t.TempDir()
+			f, err := newDiskCache(dir, 1<<20).open(context.Background(), r)
+			if err != nil {
+				// Entries which fail verification are discarded
+				entries, dirErr := os.ReadDir(dir)
+				require.NoError(t, dirErr)
+				require.Empty(t, entries)
+				return nil, err
+			}
+			defer f.Close()
+			return io.ReadAll(f)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.read(newReader(good[:]))
+			require.NoError(t, err)
+			require.Equal(t, data, got)
+
+			_, err = tc.read(newReader(bad[:]))
+			require.ErrorIs(t, err, ErrChecksumMismatch)
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package remotefilez
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const diskCacheTempPrefix = ".tmp-"
+
+// cacheSource is a remote reader which can be stored in the disk cache.
+type cacheSource interface {
+	io.ReadCloser
+	// objectKey returns a key which uniquely identifies the version of the
+	// object being read.
+	objectKey() string
+	Size() (int64, error)
+}
+
+// contextWriterTo is implemented by sources which can write their remainder to
+// a writer under a per-operation context.
+type contextWriterTo interface {
+	writeTo(ctx context.Context, w io.Writer) (int64, error)
+}
+
+// cachedFile is a local copy of a remote file. Stat describes the remote file.
+type cachedFile struct {
+	*sizedFile
+	info FileInfo
+}
+
+// FileInfo returns the properties of the remote file.
+func (f *cachedFile) FileInfo() (FileInfo, error) {
+	return f.info, nil
+}
+
+// diskCache is a size-bounded directory of local copies of remote objects,
+// keyed by object URL and version. Least recently used copies are evicted when
+// the directory grows beyond its size limit.
+//
+// The directory may be shared by several processes. Entries are written to a
+// temporary file and renamed into place, so partially written entries are
+// never served.
+type diskCache struct {
+	dir string
+	max int64
+	mtx sync.Mutex
+}
+
+func newDiskCache(dir string, maxBytes int64) *diskCache {
+	return &diskCache{dir: dir, max: maxBytes}
+}
+
+// open returns a local copy of src, downloading it into the cache if it is not
+// already present. src is consumed from its current offset and closed. Objects
+// larger than the cache size limit are rejected. The provided context governs
+// the copy of src, if it is a contextWriterTo.
+func (c *diskCache) open(ctx context.Context, src cacheSource) (ReaderAtSeekCloser, error) {
+	n, err := src.Size()
+	if err != nil {
+		return nil, err
+	}
+	if n > c.max {
+		return nil, errors.New("object does not fit in disk cache")
+	}
+	sum := sha256.Sum256([]byte(src.objectKey()))
+	fpath := filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+	wrap := func(f *os.File) (ReaderAtSeekCloser, error) {
+		if s, ok := src.(Stater); ok {
+			info, err := s.FileInfo()
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			return &cachedFile{sizedFile: &sizedFile{File: f}, info: info}, nil
+		}
+		return &sizedFile{File: f}, nil
+	}
+
+	// Cache hit
+	if f, err := os.Open(fpath); err == nil {
+		src.Close()
+		now := time.Now()
+		_ = os.Chtimes(fpath, now, now)
+		return wrap(f)
+	}
+
+	// Cache miss
+	if err := os.MkdirAll(c.dir, 0777); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(c.dir, diskCacheTempPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if wt, ok := src.(contextWriterTo); ok {
+		_, err = wt.writeTo(ctx, tmp)
+	} else {
+		_, err = io.Copy(tmp, src)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if srcErr := src.Close(); err == nil {
+		err = srcErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), fpath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	if err := c.evict(fpath); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	return wrap(f)
+}
+
+// evict removes least recently used entries until the cache fits within its
+// size limit. The entry at keep is never removed.
+func (c *diskCache) evict(keep string) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	dirents, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	var size int64
+	entries := make([]fs.FileInfo, 0, len(dirents))
+	for _, d := range dirents {
+		if d.IsDir() || strings.HasPrefix(d.Name(), diskCacheTempPrefix) {
+			continue
+		}
+		fi, err := d.Info()
+		if err != nil {
+			// Removed by another process
+			continue
+		}
+		size += fi.Size()
+		entries = append(entries, fi)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+	for _, fi := range entries {
+		if size <= c.max {
+			break
+		}
+		fpath := filepath.Join(c.dir, fi.Name())
+		if fpath == keep {
+			continue
+		}
+		if err := os.Remove(fpath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		size -= fi.Size()
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package remotefilez
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memCacheSource is an in-memory cacheSource.
+type memCacheSource struct {
+	*bytes.Reader
+	key    string
+	closed bool
+}
+
+func (m *memCacheSource) objectKey() string {
+	return m.key
+}
+
+func (m *memCacheSource) Size() (int64, error) {
+	return m.Reader.Size(), nil
+}
+
+func (m *memCacheSource) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	c := newDiskCache(dir, 100)
+	open := func(key string, data []byte) []byte {
+		src := &memCacheSource{Reader: bytes.NewReader(data), key: key}
+		f, err := c.open(context.Background(), src)
+		require.NoError(t, err)
+		require.True(t, src.closed)
+		defer f.Close()
+		got, err := io.ReadAll(f)
+		require.NoError(t, err)
+		return got
+	}
+	countEntries := func() int {
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		return len(entries)
+	}
+
+	a := bytes.Repeat([]byte("a"), 40)
+	b := bytes.Repeat([]byte("b"), 40)
+	require.Equal(t, a, open("a@1", a))
+	require.Equal(t, b, open("b@1", b))
+	require.Equal(t, 2, countEntries())
+
+	// Cache hits do not consume the source
+	require.Equal(t, a, open("a@1", nil))
+
+	// A new version of a is a different entry, which evicts b, the least
+	// recently used entry
+	a2 := bytes.Repeat([]byte("A"), 40)
+	require.Equal(t, a2, open("a@2", a2))
+	require.Equal(t, 2, countEntries())
+	require.Equal(t, a, open("a@1", nil))
+
+	// Objects larger than the cache are rejected
+	_, err := c.open(context.Background(), &memCacheSource{
+		Reader: bytes.NewReader(make([]byte, 101)),
+		key:    "large@1",
+	})
+	require.Error(t, err)
+	// Entries are filled from the current offset of the source
+	src := &memCacheSource{Reader: bytes.NewReader([]byte("skipped data")), key: "offset@1"}
+	_, err = src.Seek(8, io.SeekStart)
+	require.NoError(t, err)
+	f, err := c.open(context.Background(), src)
+	require.NoError(t, err)
+	defer f.Close()
+	got, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, "data", string(got))
+}
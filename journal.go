@@ -0,0 +1,180 @@
+package remotefilez
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// uploadJournal records the blocks staged by a resumable upload, so that a
+// restarted upload can skip them. The journal is a file of JSON lines, a
+// header followed by one entry per staged block. A journal without a path
+// only provides block IDs, and records nothing.
+type uploadJournal struct {
+	path string
+	hdr  journalHeader
+	mtx  sync.Mutex
+	f    *os.File
+}
+
+// journalHeader identifies the upload of a journal.
+type journalHeader struct {
+	URL       string `json:"url"`
+	BlockSize int64  `json:"blockSize"`
+	UploadID  string `json:"uploadId"`
+}
+
+// journalEntry describes a staged block.
+type journalEntry struct {
+	Index int   `json:"index"`
+	Size  int64 `json:"size"`
+
+	// MD5 is the marshaled state of the checksum of all data up to and
+	// including the block, if checksums are enabled.
+	MD5 []byte `json:"md5,omitempty"`
+}
+
+// openUploadJournal opens the journal at path for an upload to url. If the
+// journal exists, its block size takes precedence over blockSize and the
+// longest run of consecutive blocks staged by a previous upload is returned.
+func openUploadJournal(
+	path string,
+	url string,
+	blockSize int64,
+) (*uploadJournal, []journalEntry, error) {
+	j := &uploadJournal{path: path}
+	var data []byte
+	var err error
+	if path == "" {
+		err = os.ErrNotExist
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		var id [8]byte
+		if _, err := rand.Read(id[:]); err != nil {
+			return nil, nil, err
+		}
+		j.hdr = journalHeader{URL: url, BlockSize: blockSize, UploadID: hex.EncodeToString(id[:])}
+		return j, nil, j.rewrite(nil)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	if !sc.Scan() || json.Unmarshal(sc.Bytes(), &j.hdr) != nil {
+		return nil, nil, fmt.Errorf("invalid journal %v", path)
+	}
+	if j.hdr.URL != url {
+		return nil, nil, fmt.Errorf("journal %v belongs to an upload to %v", path, j.hdr.URL)
+	}
+	staged := make(map[int]journalEntry)
+	for sc.Scan() {
+		var e journalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// The last line may be torn by a crash
+			break
+		}
+		staged[e.Index] = e
+	}
+	var entries []journalEntry
+	for i := 0; ; i++ {
+		e, ok := staged[i]
+		if !ok {
+			break
+		}
+		entries = append(entries, e)
+	}
+	return j, entries, j.rewrite(entries)
+}
+
+// rewrite replaces the journal with one which contains the provided entries,
+// and opens it for appending.
+func (j *uploadJournal) rewrite(entries []journalEntry) error {
+	if j.path == "" {
+		return nil
+	}
+	if j.f != nil {
+		j.f.Close()
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if err := enc.Encode(j.hdr); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	f, err := createTemp(j.path)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(buf.Bytes())
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), j.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	j.f, err = os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0)
+	return err
+}
+
+// blockID returns the ID of the block with the provided index. IDs of all
+// blocks of an upload have the same length, as required by Azure.
+func (j *uploadJournal) blockID(idx int) string {
+	return base64.StdEncoding.EncodeToString(
+		[]byte(fmt.Sprintf("%v-%06d", j.hdr.UploadID, idx)),
+	)
+}
+
+// append records a staged block.
+func (j *uploadJournal) append(e journalEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	if j.f == nil {
+		return nil
+	}
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+// close closes the journal, keeping it for a later upload.
+func (j *uploadJournal) close() error {
+	if j.f == nil {
+		return nil
+	}
+	return j.f.Close()
+}
+
+// remove closes and removes the journal once the upload is complete.
+func (j *uploadJournal) remove() error {
+	if j.f == nil {
+		return nil
+	}
+	j.f.Close()
+	return os.Remove(j.path)
+}
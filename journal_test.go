@@ -0,0 +1,54 @@
+package remotefilez
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upload.journal")
+	const url = "https://acct.blob.core.windows.net/c/blob"
+
+	j, entries, err := openUploadJournal(path, url, 4)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+	id0 := j.blockID(0)
+	require.Len(t, j.blockID(12345), len(id0))
+
+	// Blocks may be staged out of order
+	require.NoError(t, j.append(journalEntry{Index: 1, Size: 4}))
+	require.NoError(t, j.append(journalEntry{Index: 0, Size: 4}))
+	require.NoError(t, j.append(journalEntry{Index: 3, Size: 2}))
+	require.NoError(t, j.close())
+
+	// A torn line at the end of the journal is ignored
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"index":2,"si`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// The block size of the journal takes precedence, and only consecutive
+	// blocks are resumed
+	j, entries, err = openUploadJournal(path, url, 8)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), j.hdr.BlockSize)
+	require.Equal(t, id0, j.blockID(0))
+	require.Equal(t, []journalEntry{{Index: 0, Size: 4}, {Index: 1, Size: 4}}, entries)
+	require.NoError(t, j.append(journalEntry{Index: 2, Size: 1}))
+	require.NoError(t, j.close())
+
+	j, entries, err = openUploadJournal(path, url, 8)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	_, _, err = openUploadJournal(path, url+"2", 8)
+	require.Error(t, err)
+
+	require.NoError(t, j.remove())
+	_, err = os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
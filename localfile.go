@@ -1,6 +1,23 @@
 package remotefilez
 
-import "os"
+import (
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// Interface guards
+var _ WriteAborter = (*localWriter)(nil)
+var _ io.ReaderFrom = (*localWriter)(nil)
 
 type sizedFile struct {
 	*os.File
@@ -13,3 +30,348 @@ func (f *sizedFile) Size() (int64, error) {
 	}
 	return fi.Size(), err
 }
+
+// FileInfo returns information about the file.
+func (f *sizedFile) FileInfo() (FileInfo, error) {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return localFileInfo(f.File.Name(), fi)
+}
+
+// localWriter writes to a temporary file next to the target, which replaces
+// the target once the writer is closed. Readers of the target thus see either
+// the old or the new contents, never a partially written file.
+//
+// Symlinks are followed, so that the file they point to is replaced rather
+// than the link. Targets which are not regular files, such as devices and
+// FIFOs, cannot be replaced. They are written in place, and writes to them
+// cannot be aborted.
+type localWriter struct {
+	path string
+	meta *localMeta
+	cond writeCondition
+	mtx  sync.Mutex
+	f    *os.File
+	md5  hash.Hash
+	err  error
+
+	// inPlace is set if f is the target itself
+	inPlace bool
+}
+
+// newLocalWriter returns a writer to the file at path.
+func newLocalWriter(path string, opts *WriterOptions) (*localWriter, error) {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	cond := writeCondition{ifNotExists: opts.IfNotExists, ifMatch: opts.IfMatch}
+	if err := cond.check(path); err != nil {
+		return nil, err
+	}
+	if fi, err := os.Lstat(path); err == nil && !fi.Mode().IsRegular() {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &localWriter{path: path, f: f, inPlace: true}, nil
+	}
+	f, err := createTemp(path)
+	if err != nil {
+		return nil, err
+	}
+	// Keep the permissions of an existing target
+	if fi, err := os.Stat(path); err == nil {
+		if err := f.Chmod(fi.Mode().Perm()); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return nil, err
+		}
+	}
+	w := &localWriter{
+		path: path,
+		meta: newLocalMeta(path, opts),
+		cond: cond,
+		f:    f,
+	}
+	if opts.checksum {
+		w.md5 = md5.New()
+	}
+	return w, nil
+}
+
+// createTemp creates a new, empty file in the same directory as path.
+func createTemp(path string) (*os.File, error) {
+	dir, base := filepath.Split(path)
+	for i := 0; i < 10000; i++ {
+		name := filepath.Join(dir, "."+base+".tmp-"+strconv.FormatUint(uint64(rand.Uint32()), 36))
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		return f, err
+	}
+	return nil, fmt.Errorf("create temporary file for %v failed", path)
+}
+
+// Write implements io.Writer
+func (w *localWriter) Write(p []byte) (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.f.Write(p)
+	if w.md5 != nil {
+		w.md5.Write(p[:n])
+	}
+	return n, err
+}
+
+// ReadFrom implements io.ReaderFrom
+func (w *localWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.md5 != nil {
+		return io.Copy(io.MultiWriter(w.f, w.md5), r)
+	}
+	return w.f.ReadFrom(r)
+}
+
+// Close replaces the target file with the written data. Both the data and
+// the rename are flushed to stable storage before Close returns.
+func (w *localWriter) Close() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.err = os.ErrClosed
+	if w.inPlace {
+		return w.f.Close()
+	}
+	err := w.f.Sync()
+	if closeErr := w.f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = w.cond.check(w.path)
+	}
+	if err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+
+	// The sidecar is replaced before the data, so that the new data is never
+	// described by the old sidecar. It is restored if the commit fails.
+	prev, _ := readLocalMeta(w.path)
+	meta := w.meta
+	if w.md5 != nil {
+		var m localMeta
+		if meta != nil {
+			m = *meta
+		}
+		m.ContentMD5 = w.md5.Sum(nil)
+		meta = &m
+	}
+	err = writeLocalMeta(w.path, meta)
+	if err == nil {
+		if err = w.commit(); err != nil {
+			restoreLocalMeta(w.path, prev)
+		}
+	}
+	if err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(w.path))
+}
+
+// commit moves the temporary file to the target.
+func (w *localWriter) commit() error {
+	if !w.cond.ifNotExists {
+		return os.Rename(w.f.Name(), w.path)
+	}
+	// Unlike rename, link fails if the target exists
+	if err := os.Link(w.f.Name(), w.path); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%w, %v already exists", ErrPrecondition, w.path)
+		}
+		return err
+	}
+	return os.Remove(w.f.Name())
+}
+
+// writeCondition is a precondition for writing a local file.
+type writeCondition struct {
+	ifNotExists bool
+	ifMatch     string
+}
+
+// check returns ErrPrecondition if the file at path does not satisfy the
+// condition.
+func (c writeCondition) check(path string) error {
+	if !c.ifNotExists && c.ifMatch == "" {
+		return nil
+	}
+	fi, err := os.Stat(path)
+	switch {
+	case err == nil && c.ifNotExists:
+		return fmt.Errorf("%w, %v already exists", ErrPrecondition, path)
+	case errors.Is(err, os.ErrNotExist) && c.ifNotExists:
+		return nil
+	case errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("%w, %v does not exist", ErrPrecondition, path)
+	case err != nil:
+		return err
+	}
+	info, err := localFileInfo(path, fi)
+	if err != nil {
+		return err
+	}
+	if info.ETag != c.ifMatch {
+		return fmt.Errorf("%w, etag %v does not match %v", ErrPrecondition, info.ETag, c.ifMatch)
+	}
+	return nil
+}
+
+// syncDir flushes the directory entries of dir to stable storage.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		// Directories cannot be synced on Windows
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// CloseWithError discards the written data, leaving the target untouched.
+// Data written in place, to a target which is not a regular file, is kept.
+func (w *localWriter) CloseWithError(err error) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.err != nil {
+		return nil
+	}
+	if err == nil {
+		err = ErrWriteAborted
+	}
+	w.err = err
+	if w.inPlace {
+		// Data written in place cannot be discarded
+		return w.f.Close()
+	}
+	w.f.Close()
+	return os.Remove(w.f.Name())
+}
+
+// localMeta contains the properties of a local file which cannot be stored
+// in the file system. It is stored as JSON in a sidecar file next to the file.
+type localMeta struct {
+	ContentType     string            `json:"contentType,omitempty"`
+	ContentEncoding string            `json:"contentEncoding,omitempty"`
+	CacheControl    string            `json:"cacheControl,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	ContentMD5      []byte            `json:"contentMD5,omitempty"`
+}
+
+// newLocalMeta returns the properties of the file at path which was written
+// with the provided options, or nil if there are none.
+func newLocalMeta(path string, opts *WriterOptions) *localMeta {
+	meta := localMeta{
+		ContentType:     opts.contentType(path),
+		ContentEncoding: opts.ContentEncoding,
+		CacheControl:    opts.CacheControl,
+		Metadata:        opts.Metadata,
+	}
+	if meta.empty() {
+		return nil
+	}
+	return &meta
+}
+
+// empty returns true if m describes no properties.
+func (m *localMeta) empty() bool {
+	return m.ContentType == "" && m.ContentEncoding == "" && m.CacheControl == "" &&
+		len(m.Metadata) == 0 && len(m.ContentMD5) == 0
+}
+
+// localMetaPath returns the path of the sidecar file of the file at path.
+// Symlinks share the sidecar file of the file they point to.
+func localMetaPath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	dir, base := filepath.Split(path)
+	return filepath.Join(dir, "."+base+".meta.json")
+}
+
+// writeLocalMeta replaces the sidecar file of the file at path. A nil meta
+// removes any existing sidecar file.
+func writeLocalMeta(path string, meta *localMeta) error {
+	metaPath := localMetaPath(path)
+	if meta == nil {
+		if err := os.Remove(metaPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	f, err := createTemp(metaPath)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), metaPath)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// readLocalMeta reads the sidecar file of the file at path. Files without a
+// sidecar file have no properties.
+func readLocalMeta(path string) (localMeta, error) {
+	var meta localMeta
+	data, err := os.ReadFile(localMetaPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return meta, nil
+	}
+	if err != nil {
+		return meta, err
+	}
+	err = json.Unmarshal(data, &meta)
+	return meta, err
+}
+
+// restoreLocalMeta restores the sidecar file of the file at path, as returned
+// by readLocalMeta, on a best-effort basis.
+func restoreLocalMeta(path string, prev localMeta) {
+	if prev.empty() {
+		writeLocalMeta(path, nil)
+		return
+	}
+	writeLocalMeta(path, &prev)
+}
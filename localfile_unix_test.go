@@ -0,0 +1,38 @@
+//go:build unix
+
+package remotefilez_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/sebnyberg/remotefilez"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalWriterFIFO(t *testing.T) {
+	fifo := t.TempDir() + "/fifo"
+	require.NoError(t, syscall.Mkfifo(fifo, 0666))
+
+	// Opening a FIFO for writing blocks until it has a reader
+	read := make(chan []byte)
+	go func() {
+		data, err := os.ReadFile(fifo)
+		require.NoError(t, err)
+		read <- data
+	}()
+
+	var p remotefilez.Opener
+	w, err := p.OpenWriterWithOptions(context.Background(), "file://"+fifo, nil)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("through the pipe"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Equal(t, "through the pipe", string(<-read))
+
+	fi, err := os.Lstat(fifo)
+	require.NoError(t, err)
+	require.NotZero(t, fi.Mode()&os.ModeNamedPipe)
+}
@@ -0,0 +1,154 @@
+package remotefilez
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// Interface guards
+var _ ReaderAtSeekCloser = (*mmapFile)(nil)
+var _ Stater = (*mmapFile)(nil)
+var _ Mapped = (*mmapFile)(nil)
+
+// Mapped is implemented by memory-mapped file handles, see Opener.WithMmap.
+type Mapped interface {
+	// Bytes returns the contents of the file. The slice must not be modified,
+	// and must not be used after the file has been closed.
+	Bytes() []byte
+}
+
+// mmapFile is a read-only memory-mapped local file.
+type mmapFile struct {
+	mapping []byte
+	data    []byte
+	path    string
+	fi      os.FileInfo
+
+	// mtx guards the mapping, which is unmapped by Close. ReadAt only needs a
+	// read lock, so that concurrent ReadAt calls do not serialize.
+	mtx    sync.RWMutex
+	off    int64
+	closed bool
+}
+
+// newMmapFile maps the provided range of f into memory. f may be closed once
+// the file has been mapped.
+func newMmapFile(f *os.File, rng ByteRange) (*mmapFile, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	off, n, err := rng.clamp(fi.Size())
+	if err != nil {
+		return nil, err
+	}
+	var mapping []byte
+	if fi.Size() > 0 {
+		mapping, err = mmap(f, fi.Size())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &mmapFile{
+		mapping: mapping,
+		data:    mapping[off : off+n],
+		path:    f.Name(),
+		fi:      fi,
+	}, nil
+}
+
+// Bytes returns the mapped contents of the file.
+func (f *mmapFile) Bytes() []byte {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+	return f.data
+}
+
+// Read implements io.Reader.
+func (f *mmapFile) Read(p []byte) (int, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	n, err := f.readAt(p, f.off)
+	f.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt implements io.ReaderAt.
+func (f *mmapFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+	return f.readAt(p, off)
+}
+
+// readAt is a concurrency-unsafe version of .ReadAt(). You must hold f.mtx
+// before calling this function.
+func (f *mmapFile) readAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, errors.New("read on closed file")
+	}
+	if off < 0 {
+		return 0, errors.New("offset out of bounds")
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Seek implements io.Seeker.
+func (f *mmapFile) Seek(offset int64, whence int) (int64, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += int64(len(f.data))
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("offset out of bounds")
+	}
+	f.off = offset
+	return f.off, nil
+}
+
+// Size returns the size of the mapped range.
+func (f *mmapFile) Size() (int64, error) {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+	return int64(len(f.data)), nil
+}
+
+// FileInfo returns information about the underlying file.
+func (f *mmapFile) FileInfo() (FileInfo, error) {
+	return localFileInfo(f.path, f.fi)
+}
+
+// Close unmaps the file.
+func (f *mmapFile) Close() error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	data := f.mapping
+	f.mapping = nil
+	f.data = nil
+	if data == nil {
+		return nil
+	}
+	return munmap(data)
+}
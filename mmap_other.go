@@ -0,0 +1,16 @@
+//go:build !unix
+
+package remotefilez
+
+import (
+	"fmt"
+	"os"
+)
+
+func mmap(f *os.File, size int64) ([]byte, error) {
+	return nil, fmt.Errorf("%w, mmap is not supported on this platform", ErrNotImplemented)
+}
+
+func munmap(b []byte) error {
+	return nil
+}
@@ -0,0 +1,141 @@
+package remotefilez
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Interface guards
+var _ ReaderAtSeekCloser = (*multiReader)(nil)
+
+// multiReader presents several files as one logical, contiguous file.
+type multiReader struct {
+	parts []ReaderAtSeekCloser
+	// start[i] is the offset of parts[i] within the logical file, and
+	// start[len(parts)] is the total size.
+	start []int64
+
+	mtx sync.Mutex
+	off int64
+}
+
+// OpenMulti opens the files at the provided URLs, which may belong to
+// different backends, and returns a single handle which reads them as one
+// contiguous file, in order. Size returns the total size of all files.
+func (ro *Opener) OpenMulti(ctx context.Context, fileURLs ...string) (ReaderAtSeekCloser, error) {
+	mr := &multiReader{
+		parts: make([]ReaderAtSeekCloser, 0, len(fileURLs)),
+		start: make([]int64, 1, len(fileURLs)+1),
+	}
+	for _, fileURL := range fileURLs {
+		r, err := ro.OpenReaderCtx(ctx, fileURL)
+		if err != nil {
+			mr.Close()
+			return nil, err
+		}
+		mr.parts = append(mr.parts, r)
+		n, err := r.Size()
+		if err != nil {
+			mr.Close()
+			return nil, err
+		}
+		mr.start = append(mr.start, mr.start[len(mr.start)-1]+n)
+	}
+	return mr, nil
+}
+
+// Read implements io.Reader. A single read never spans more than one part.
+func (mr *multiReader) Read(p []byte) (int, error) {
+	mr.mtx.Lock()
+	defer mr.mtx.Unlock()
+	n, err := mr.readAt(p, mr.off)
+	mr.off += int64(n)
+	return n, err
+}
+
+// ReadAt implements io.ReaderAt.
+func (mr *multiReader) ReadAt(p []byte, off int64) (int, error) {
+	mr.mtx.Lock()
+	defer mr.mtx.Unlock()
+	var n int
+	for n < len(p) {
+		m, err := mr.readAt(p[n:], off+int64(n))
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// readAt reads from the part which contains off. You must hold mr.mtx before
+// calling this function.
+func (mr *multiReader) readAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("offset out of bounds")
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	size := mr.start[len(mr.parts)]
+	if off >= size {
+		return 0, io.EOF
+	}
+
+	// Find the first part which ends after off, which skips empty parts
+	i := sort.Search(len(mr.parts), func(i int) bool {
+		return mr.start[i+1] > off
+	})
+	partOff := off - mr.start[i]
+	remaining := mr.start[i+1] - off
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := mr.parts[i].ReadAt(p, partOff)
+	if err == io.EOF && n == len(p) {
+		err = nil
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (mr *multiReader) Seek(offset int64, whence int) (int64, error) {
+	mr.mtx.Lock()
+	defer mr.mtx.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += mr.off
+	case io.SeekEnd:
+		offset += mr.start[len(mr.parts)]
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("offset out of bounds")
+	}
+	mr.off = offset
+	return mr.off, nil
+}
+
+// Size returns the total size of all parts.
+func (mr *multiReader) Size() (int64, error) {
+	return mr.start[len(mr.parts)], nil
+}
+
+// Close closes all parts and returns the first error encountered.
+func (mr *multiReader) Close() error {
+	var firstErr error
+	for _, r := range mr.parts {
+		if err := r.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -18,15 +18,35 @@ type ReaderAtSeekCloser interface {
 	Size() (int64, error)
 }
 
+// ContextReader is implemented by remote file handles, which accept a context
+// per operation. The context passed when opening a file only governs the open
+// call, and the context passed to each operation only governs that operation.
+type ContextReader interface {
+	ReadCtx(ctx context.Context, p []byte) (int, error)
+	ReadAtCtx(ctx context.Context, p []byte, off int64) (int, error)
+	SeekCtx(ctx context.Context, offset int64, whence int) (int64, error)
+}
+
 const (
 	schemeFile  = "file"
 	schemeAzure = "abs"
 )
 
+// Query parameters which select a specific, immutable version of a file.
+const (
+	querySnapshot  = "snapshot"
+	queryVersionID = "versionid"
+)
+
 var (
 	ErrRelativePath      = errors.New("relative path")
 	ErrUnsupportedScheme = errors.New("unsupported scheme")
 	ErrNotImplemented    = errors.New("not implemented")
+	ErrObjectChanged     = errors.New("object changed since it was opened")
+	ErrInvalidRange      = errors.New("invalid byte range")
+	ErrWriteAborted      = errors.New("write aborted")
+	ErrPrecondition      = errors.New("precondition failed")
+	ErrChecksumMismatch  = errors.New("checksum mismatch")
 )
 
 // Opener provides a unified interface for resolving io.ReadSeekClosers from
@@ -35,6 +55,16 @@ type Opener struct {
 	azcreds        azcore.TokenCredential
 	azOpenTimeout  time.Duration
 	azDoAccounting bool
+
+	readAheadBlockSize int64
+	readAheadDepth     int
+	blockCache         *blockCache
+	diskCache          *diskCache
+	retryPolicy        RetryPolicy
+	mmap               bool
+	noMkdirAll         bool
+	dirPerm            os.FileMode
+	checksums          bool
 }
 
 // WithAzureResolver returns a copy of the Opener with the provided Azure
@@ -50,6 +80,76 @@ func (ro Opener) WithAzureResolver(
 	return &ro
 }
 
+// WithReadAhead returns a copy of the Opener which reads remote files in
+// blocks of blockSize bytes, prefetching up to depth blocks in the background
+// while the current block is consumed. A depth of zero disables read-ahead.
+func (ro Opener) WithReadAhead(blockSize int64, depth int) *Opener {
+	ro.readAheadBlockSize = blockSize
+	ro.readAheadDepth = depth
+	return &ro
+}
+
+// WithBlockCache returns a copy of the Opener with an in-memory LRU cache of
+// remote file blocks, bounded to maxBytes. The cache is shared by all readers
+// opened by the returned Opener, so repeated reads of the same regions of an
+// object (footers, indexes, headers) are served without network requests.
+// Blocks are sized according to WithReadAhead.
+func (ro Opener) WithBlockCache(maxBytes int64) *Opener {
+	ro.blockCache = newBlockCache(maxBytes)
+	return &ro
+}
+
+// WithDiskCache returns a copy of the Opener which keeps local copies of remote
+// files in dir, bounded to maxBytes in total. Copies are keyed by URL and
+// version (ETag), so a cached copy is only served while the remote file is
+// unchanged. Least recently used copies are evicted first. Remote files larger
+// than maxBytes are read directly.
+//
+// The cache directory may be shared across processes and process runs.
+func (ro Opener) WithDiskCache(dir string, maxBytes int64) *Opener {
+	ro.diskCache = newDiskCache(dir, maxBytes)
+	return &ro
+}
+
+// WithRetryPolicy returns a copy of the Opener which uses the provided policy
+// to resume remote reads after broken download streams.
+func (ro Opener) WithRetryPolicy(p RetryPolicy) *Opener {
+	ro.retryPolicy = p
+	return &ro
+}
+
+// WithMmap returns a copy of the Opener which memory-maps local files instead
+// of reading them with system calls. This is much faster for many small
+// ReadAt calls. Handles of memory-mapped files implement Mapped, which gives
+// direct access to the file contents.
+func (ro Opener) WithMmap(enabled bool) *Opener {
+	ro.mmap = enabled
+	return &ro
+}
+
+// WithParentDirs returns a copy of the Opener which controls whether local
+// writes and downloads create missing parent directories, like blob storage
+// does implicitly. Directories are created with perm (before umask), or 0777
+// if perm is zero. Parent directories are created by default.
+func (ro Opener) WithParentDirs(create bool, perm os.FileMode) *Opener {
+	ro.noMkdirAll = !create
+	ro.dirPerm = perm
+	return &ro
+}
+
+// WithChecksums returns a copy of the Opener which protects data end-to-end
+// with MD5 checksums. Writers compute the MD5 of written data and store it as
+// the Content-MD5 of the file. Remote readers which read an entire file
+// sequentially, with Read or WriteTo, verify its contents against the stored
+// MD5 at EOF, and fail with ErrChecksumMismatch if they differ. This includes
+// readers with read-ahead or a block cache. Disk cache entries are verified
+// when they are filled, and Download verifies the file before it replaces the
+// local copy. Files without a stored MD5 are not verified.
+func (ro Opener) WithChecksums(enabled bool) *Opener {
+	ro.checksums = enabled
+	return &ro
+}
+
 // Open returns an io.ReadSeekCloser handle from the provided file URL.
 //
 // Depecated: Use OpenReader instead.
@@ -73,29 +173,73 @@ func (ro *Opener) OpenReader(fileURL string) (ReaderAtSeekCloser, error) {
 
 // OpenReaderCtx returns an io.ReadSeekCloser handle from the provided file URL.
 // Errors if a resolver for the provided schema is not registered.
+//
+// A specific snapshot or version of an Azure blob is opened by adding a
+// snapshot or versionid query parameter to the URL, e.g.
+// abs://acct.blob.core.windows.net/cnt/blob.txt?versionid=<id>.
+//
+// A range of the file is opened by adding a fragment on the form
+// #bytes=<first>-<last>, see OpenRange.
 func (ro *Opener) OpenReaderCtx(ctx context.Context, fileURL string) (ReaderAtSeekCloser, error) {
-	u, err := url.Parse(fileURL)
+	u, err := parseFileURL(fileURL)
 	if err != nil {
-		return nil, fmt.Errorf("parse URL failed, %w", err)
+		return nil, err
 	}
-
-	// Best-effort to detect absolute paths
-	if len(fileURL) >= len(u.Scheme)+4 && fileURL[len(u.Scheme)+3] == '.' {
-		return nil, fmt.Errorf("%w not supported", ErrRelativePath)
+	rng := fullRange
+	if u.Fragment != "" {
+		rng, err = parseByteRange(u.Fragment)
+		if err != nil {
+			return nil, err
+		}
+		u.Fragment = ""
 	}
+	return ro.openReader(ctx, u, rng)
+}
 
+// openReader opens the provided range of the file at u.
+func (ro *Opener) openReader(ctx context.Context, u *url.URL, rng ByteRange) (ReaderAtSeekCloser, error) {
 	switch u.Scheme {
 	case schemeFile:
+		if q := u.Query(); q.Has(querySnapshot) || q.Has(queryVersionID) {
+			return nil, fmt.Errorf("%w, local files are not versioned", ErrNotImplemented)
+		}
 		f, err := os.Open(u.Path)
 		if err != nil {
 			return nil, err
 		}
-		return &sizedFile{File: f}, nil
+		if ro.mmap {
+			defer f.Close()
+			return newMmapFile(f, rng)
+		}
+		if rng == fullRange {
+			return &sizedFile{File: f}, nil
+		}
+		sf, err := newSectionFile(f, rng)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return sf, nil
 	case schemeAzure:
 		if ro.azcreds == nil {
 			return nil, errors.New("missing credentials please add AzureResolver")
 		}
-		return NewAzureBlobReader(ctx, fileURL, ro.azcreds, ro.azOpenTimeout, ro.azDoAccounting)
+		r, err := newAzureBlobReader(ctx, u.String(), ro.azcreds, azReaderOptions{
+			rng:    rng,
+			retry:  ro.retryPolicy,
+			doAcct: ro.azDoAccounting,
+			verify: ro.checksums,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ro.diskCache != nil && r.n <= ro.diskCache.max {
+			return ro.diskCache.open(ctx, r)
+		}
+		if ro.readAheadDepth > 0 || ro.blockCache != nil {
+			return newBlockReader(r, ro.readAheadBlockSize, ro.readAheadDepth, ro.blockCache)
+		}
+		return r, nil
 	default:
 		return nil, fmt.Errorf("%w %q", ErrUnsupportedScheme, u.Scheme)
 	}
@@ -109,7 +253,14 @@ func (ro *Opener) OpenWriter(fileURL string) (io.WriteCloser, error) {
 
 // OpenCtx returns an io.ReadSeekCloser handle from the provided file URL.
 // Errors if a resolver for the provided schema is not registered.
+//
+// The returned writer implements WriteAborter.
 func (ro *Opener) OpenWriterCtx(ctx context.Context, fileURL string) (io.WriteCloser, error) {
+	return ro.OpenWriterWithOptions(ctx, fileURL, nil)
+}
+
+// parseFileURL parses the provided file URL.
+func parseFileURL(fileURL string) (*url.URL, error) {
 	u, err := url.Parse(fileURL)
 	if err != nil {
 		return nil, fmt.Errorf("parse URL failed, %w", err)
@@ -119,16 +270,5 @@ func (ro *Opener) OpenWriterCtx(ctx context.Context, fileURL string) (io.WriteCl
 	if len(fileURL) >= len(u.Scheme)+4 && fileURL[len(u.Scheme)+3] == '.' {
 		return nil, fmt.Errorf("%w not supported", ErrRelativePath)
 	}
-
-	switch u.Scheme {
-	case schemeFile:
-		return os.OpenFile(u.Path, os.O_WRONLY|os.O_CREATE, 0666)
-	case schemeAzure:
-		if ro.azcreds == nil {
-			return nil, errors.New("missing credentials please add AzureResolver")
-		}
-		return NewAzureBlobWriteCloser(fileURL, ro.azcreds, ro.azOpenTimeout, ctx)
-	default:
-		return nil, fmt.Errorf("%w %q", ErrUnsupportedScheme, u.Scheme)
-	}
+	return u, nil
 }
@@ -1,8 +1,15 @@
 package remotefilez_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/sebnyberg/remotefilez"
@@ -22,6 +29,11 @@ func TestValidation(t *testing.T) {
 		_, err := p.Open(furi)
 		require.ErrorIs(t, err, remotefilez.ErrRelativePath)
 	})
+	t.Run("local file versions errs", func(t *testing.T) {
+		var p remotefilez.Opener
+		_, err := p.Open("file:///tmp/file.txt?versionid=1")
+		require.ErrorIs(t, err, remotefilez.ErrNotImplemented)
+	})
 
 }
 
@@ -47,3 +59,564 @@ func TestLocal(t *testing.T) {
 	})
 
 }
+
+func TestLocalEmpty(t *testing.T) {
+	fpath := t.TempDir() + "/empty"
+	require.NoError(t, os.WriteFile(fpath, nil, 0666))
+
+	var p remotefilez.Opener
+	f, err := p.Open("file://" + fpath)
+	require.NoError(t, err)
+	defer f.Close()
+	sz, err := f.Size()
+	require.NoError(t, err)
+	require.Zero(t, sz)
+	_, err = f.Read(make([]byte, 10))
+	require.ErrorIs(t, err, io.EOF)
+	_, err = f.ReadAt(make([]byte, 10), 0)
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestStat(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	fpath := dir + "/testdata/small"
+	furi := "file://" + fpath
+	want, err := os.Stat(fpath)
+	require.NoError(t, err)
+
+	var p remotefilez.Opener
+	fi, err := p.Stat(context.Background(), furi)
+	require.NoError(t, err)
+	require.Equal(t, want.Size(), fi.Size)
+	require.Equal(t, want.ModTime(), fi.ModTime)
+	require.NotEmpty(t, fi.ETag)
+
+	f, err := p.Open(furi)
+	require.NoError(t, err)
+	defer f.Close()
+	handleFi, err := f.(remotefilez.Stater).FileInfo()
+	require.NoError(t, err)
+	require.Equal(t, fi, handleFi)
+
+	// Local handles keep the Stat method of os.File
+	osFi, err := f.(fs.File).Stat()
+	require.NoError(t, err)
+	require.Equal(t, want.Size(), osFi.Size())
+}
+
+func TestDownload(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	fpath := dir + "/testdata/beowulf.txt"
+	want, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+
+	var p remotefilez.Opener
+	dst := t.TempDir() + "/beowulf.txt"
+	err = p.Download(context.Background(), "file://"+fpath, dst,
+		&remotefilez.DownloadOptions{ChunkSize: 4096, Concurrency: 3},
+	)
+	require.NoError(t, err)
+	got, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// The properties of a previous local file are dropped
+	w, err := p.OpenWriterWithOptions(context.Background(), "file://"+dst,
+		&remotefilez.WriterOptions{ContentType: "text/csv"})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	err = p.Download(context.Background(), "file://"+fpath, dst, nil)
+	require.NoError(t, err)
+	fi, err := p.Stat(context.Background(), "file://"+dst)
+	require.NoError(t, err)
+	require.Empty(t, fi.ContentType)
+
+	// Missing parent directories are created
+	nested := t.TempDir() + "/a/b/beowulf.txt"
+	err = p.Download(context.Background(), "file://"+fpath, nested, nil)
+	require.NoError(t, err)
+	got, err = os.ReadFile(nested)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// A failed download keeps the previous file
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	prev := t.TempDir() + "/beowulf.txt"
+	require.NoError(t, os.WriteFile(prev, []byte("previous"), 0666))
+	err = p.Download(ctx, "file://"+fpath, prev, nil)
+	require.ErrorIs(t, err, context.Canceled)
+	got, err = os.ReadFile(prev)
+	require.NoError(t, err)
+	require.Equal(t, "previous", string(got))
+	entries, err := os.ReadDir(filepath.Dir(prev))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestOpenMulti(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	empty := t.TempDir() + "/empty"
+	require.NoError(t, os.WriteFile(empty, nil, 0666))
+	fpaths := []string{
+		dir + "/testdata/small",
+		empty,
+		dir + "/testdata/small.csv",
+		dir + "/testdata/small",
+	}
+	var want []byte
+	var furis []string
+	for _, fpath := range fpaths {
+		contents, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		want = append(want, contents...)
+		furis = append(furis, "file://"+fpath)
+	}
+
+	var p remotefilez.Opener
+	f, err := p.OpenMulti(context.Background(), furis...)
+	require.NoError(t, err)
+	defer f.Close()
+
+	sz, err := f.Size()
+	require.NoError(t, err)
+	require.EqualValues(t, len(want), sz)
+
+	got, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Read across part boundaries
+	for off := int64(0); off < sz; off += 7 {
+		buf := make([]byte, 11)
+		n, err := f.ReadAt(buf, off)
+		if off+11 > sz {
+			require.ErrorIs(t, err, io.EOF)
+		} else {
+			require.NoError(t, err)
+		}
+		require.Equal(t, want[off:off+int64(n)], buf[:n])
+	}
+
+	off, err := f.Seek(-5, io.SeekEnd)
+	require.NoError(t, err)
+	require.Equal(t, sz-5, off)
+	got, err = io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, want[sz-5:], got)
+}
+
+func TestOpenRange(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	fpath := dir + "/testdata/beowulf.txt"
+	furi := "file://" + fpath
+	contents, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name string
+		open func(p *remotefilez.Opener) (remotefilez.ReaderAtSeekCloser, error)
+		want []byte
+	}{
+		{"fragment", func(p *remotefilez.Opener) (remotefilez.ReaderAtSeekCloser, error) {
+			return p.OpenReaderCtx(ctx, furi+"#bytes=1000-1999")
+		}, contents[1000:2000]},
+		{"open-ended fragment", func(p *remotefilez.Opener) (remotefilez.ReaderAtSeekCloser, error) {
+			return p.OpenReaderCtx(ctx, furi+"#bytes=10000-")
+		}, contents[10000:]},
+		{"OpenRange", func(p *remotefilez.Opener) (remotefilez.ReaderAtSeekCloser, error) {
+			return p.OpenRange(ctx, furi, 1000, 1000)
+		}, contents[1000:2000]},
+		{"OpenRange past end", func(p *remotefilez.Opener) (remotefilez.ReaderAtSeekCloser, error) {
+			return p.OpenRange(ctx, furi, 10000, 5000)
+		}, contents[10000:]},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var p remotefilez.Opener
+			f, err := tc.open(&p)
+			require.NoError(t, err)
+			defer f.Close()
+			sz, err := f.Size()
+			require.NoError(t, err)
+			require.EqualValues(t, len(tc.want), sz)
+			got, err := io.ReadAll(f)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+
+			buf := make([]byte, 10)
+			_, err = f.ReadAt(buf, 5)
+			require.NoError(t, err)
+			require.Equal(t, tc.want[5:15], buf)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		var p remotefilez.Opener
+		for _, fragment := range []string{"bytes=10", "bytes=20-10", "lines=1-2"} {
+			_, err := p.OpenReaderCtx(ctx, furi+"#"+fragment)
+			require.ErrorIs(t, err, remotefilez.ErrInvalidRange, fragment)
+		}
+		_, err := p.OpenRange(ctx, furi, int64(len(contents))+1, 10)
+		require.ErrorIs(t, err, remotefilez.ErrInvalidRange)
+	})
+}
+
+func TestPlanSplits(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	ctx := context.Background()
+	var p remotefilez.Opener
+
+	for _, tc := range []struct {
+		fname     string
+		splitSize int64
+		delim     []byte
+	}{
+		{"small.csv", 10, nil},
+		{"small.csv", 1000, nil},
+		{"beowulf.txt", 1000, nil},
+		{"beowulf.txt", 1, nil},
+		{"beowulf.txt", 2000, []byte(". ")},
+	} {
+		fpath := dir + "/testdata/" + tc.fname
+		contents, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		delim := tc.delim
+		if delim == nil {
+			delim = []byte("\n")
+		}
+		name := fmt.Sprintf("%v,size=%v,delim=%q", tc.fname, tc.splitSize, delim)
+		t.Run(name, func(t *testing.T) {
+			splits, err := p.PlanSplits(ctx, "file://"+fpath, tc.splitSize, tc.delim)
+			require.NoError(t, err)
+
+			// Splits must be contiguous, cover the file and end with a
+			// delimiter (except for the last split)
+			var off int64
+			for i, s := range splits {
+				require.Equal(t, off, s.Offset)
+				require.Positive(t, s.Length)
+				split := contents[s.Offset : s.Offset+s.Length]
+				if i < len(splits)-1 {
+					require.True(t, bytes.HasSuffix(split, delim))
+				}
+				off += s.Length
+			}
+			require.EqualValues(t, len(contents), off)
+			if tc.splitSize < int64(len(contents))/2 {
+				require.Greater(t, len(splits), 1)
+			}
+		})
+	}
+}
+
+func TestMmap(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	fpath := dir + "/testdata/beowulf.txt"
+	furi := "file://" + fpath
+	want, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+	p := (&remotefilez.Opener{}).WithMmap(true)
+
+	f, err := p.Open(furi)
+	require.NoError(t, err)
+	require.Equal(t, want, f.(remotefilez.Mapped).Bytes())
+	got, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+	buf := make([]byte, 100)
+	_, err = f.ReadAt(buf, 500)
+	require.NoError(t, err)
+	require.Equal(t, want[500:600], buf)
+	require.NoError(t, f.Close())
+
+	// Ranges
+	f, err = p.OpenRange(context.Background(), furi, 1000, 10)
+	require.NoError(t, err)
+	require.Equal(t, want[1000:1010], f.(remotefilez.Mapped).Bytes())
+	require.NoError(t, f.Close())
+
+	// Empty files
+	empty := t.TempDir() + "/empty"
+	require.NoError(t, os.WriteFile(empty, nil, 0666))
+	f, err = p.Open("file://" + empty)
+	require.NoError(t, err)
+	_, err = f.Read(buf)
+	require.ErrorIs(t, err, io.EOF)
+	require.NoError(t, f.Close())
+}
+
+func TestMmapConcurrentClose(t *testing.T) {
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+	furi := "file://" + dir + "/testdata/beowulf.txt"
+	f, err := (&remotefilez.Opener{}).WithMmap(true).Open(furi)
+	require.NoError(t, err)
+
+	// Reads racing with Close either succeed or fail, but never touch the
+	// unmapped file
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 100)
+			for {
+				if _, err := f.ReadAt(buf, 500); err != nil {
+					return
+				}
+			}
+		}()
+	}
+	require.NoError(t, f.Close())
+	wg.Wait()
+}
+
+func TestLocalWriter(t *testing.T) {
+	dir := t.TempDir()
+	fpath := dir + "/out"
+	furi := "file://" + fpath
+	ctx := context.Background()
+	var p remotefilez.Opener
+
+	readFile := func() string {
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		return string(data)
+	}
+	countEntries := func() int {
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		return len(entries)
+	}
+
+	t.Run("commit on close", func(t *testing.T) {
+		w, err := p.OpenWriterWithOptions(ctx, furi, nil)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("hello world"))
+		require.NoError(t, err)
+		_, err = os.Stat(fpath)
+		require.ErrorIs(t, err, os.ErrNotExist)
+		require.NoError(t, w.Close())
+		require.Equal(t, "hello world", readFile())
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		w, err := p.OpenWriter(furi)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("bye"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.Equal(t, "bye", readFile())
+	})
+
+	t.Run("keep permissions", func(t *testing.T) {
+		require.NoError(t, os.Chmod(fpath, 0600))
+		w, err := p.OpenWriter(furi)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("bye"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		fi, err := os.Stat(fpath)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		jsonURI := "file://" + dir + "/out.json"
+		w, err := p.OpenWriterWithOptions(ctx, jsonURI, &remotefilez.WriterOptions{
+			DetectContentType: true,
+			ContentEncoding:   "gzip",
+			CacheControl:      "no-cache",
+			Metadata:          map[string]string{"source": "test"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		fi, err := p.Stat(ctx, jsonURI)
+		require.NoError(t, err)
+		require.Equal(t, "application/json", fi.ContentType)
+		require.Equal(t, "gzip", fi.ContentEncoding)
+		require.Equal(t, "no-cache", fi.Extra["CacheControl"])
+		require.Equal(t, map[string]string{"source": "test"}, fi.Metadata)
+
+		// Overwriting without metadata clears it
+		w, err = p.OpenWriterWithOptions(ctx, jsonURI, nil)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		fi, err = p.Stat(ctx, jsonURI)
+		require.NoError(t, err)
+		require.Empty(t, fi.ContentType)
+		require.Empty(t, fi.Metadata)
+
+		// A sidecar which cannot be written fails the write before the data
+		// is replaced
+		metaPath := dir + "/.out.json.meta.json"
+		require.NoError(t, os.Mkdir(metaPath, 0777))
+		require.NoError(t, os.WriteFile(metaPath+"/blocker", nil, 0666))
+		w, err = p.OpenWriterWithOptions(ctx, jsonURI, &remotefilez.WriterOptions{ContentType: "text/plain"})
+		require.NoError(t, err)
+		_, err = w.Write([]byte("new"))
+		require.NoError(t, err)
+		require.Error(t, w.Close())
+		data, err := os.ReadFile(dir + "/out.json")
+		require.NoError(t, err)
+		require.Empty(t, data)
+		require.NoError(t, os.RemoveAll(metaPath))
+		require.NoError(t, os.Remove(dir+"/out.json"))
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		target := dir + "/target"
+		link := dir + "/link"
+		require.NoError(t, os.WriteFile(target, []byte("old"), 0666))
+		if err := os.Symlink(target, link); err != nil {
+			t.Skip("symlinks are not supported:", err)
+		}
+		defer os.Remove(target)
+		defer os.Remove(link)
+		w, err := p.OpenWriterWithOptions(ctx, "file://"+link, nil)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("new"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		fi, err := os.Lstat(link)
+		require.NoError(t, err)
+		require.NotZero(t, fi.Mode()&os.ModeSymlink)
+		data, err := os.ReadFile(target)
+		require.NoError(t, err)
+		require.Equal(t, "new", string(data))
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		w, err := p.OpenWriterWithOptions(ctx, furi, nil)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("partial"))
+		require.NoError(t, err)
+		require.NoError(t, w.CloseWithError(nil))
+		_, err = w.Write([]byte("more"))
+		require.ErrorIs(t, err, remotefilez.ErrWriteAborted)
+		require.ErrorIs(t, w.Close(), remotefilez.ErrWriteAborted)
+		require.Equal(t, "bye", readFile())
+		require.Equal(t, 1, countEntries())
+	})
+}
+
+func TestLocalWriterParentDirs(t *testing.T) {
+	dir := t.TempDir()
+	furi := "file://" + dir + "/a/b/out"
+	var p remotefilez.Opener
+
+	noMkdir := p.WithParentDirs(false, 0)
+	_, err := noMkdir.OpenWriter(furi)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	w, err := p.WithParentDirs(true, 0700).OpenWriter(furi)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	fi, err := os.Stat(dir + "/a/b")
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0700), fi.Mode().Perm())
+
+	// Parent directories are created by default
+	w, err = p.OpenWriter("file://" + dir + "/c/out")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+}
+
+func TestLocalWriterConditions(t *testing.T) {
+	dir := t.TempDir()
+	furi := "file://" + dir + "/out"
+	ctx := context.Background()
+	var p remotefilez.Opener
+
+	write := func(opts *remotefilez.WriterOptions, data string) error {
+		w, err := p.OpenWriterWithOptions(ctx, furi, opts)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			return err
+		}
+		return w.Close()
+	}
+	createOnly := &remotefilez.WriterOptions{IfNotExists: true}
+
+	require.ErrorIs(t, write(&remotefilez.WriterOptions{IfMatch: `"x"`}, "a"),
+		remotefilez.ErrPrecondition)
+	require.NoError(t, write(createOnly, "a"))
+	require.ErrorIs(t, write(createOnly, "b"), remotefilez.ErrPrecondition)
+
+	// Racing create-only writers: only the first to close wins, and the
+	// properties of the loser are discarded
+	w1, err := p.OpenWriterWithOptions(ctx, furi+"2",
+		&remotefilez.WriterOptions{IfNotExists: true, ContentType: "text/plain"})
+	require.NoError(t, err)
+	w2, err := p.OpenWriterWithOptions(ctx, furi+"2",
+		&remotefilez.WriterOptions{IfNotExists: true, ContentType: "text/csv"})
+	require.NoError(t, err)
+	require.NoError(t, w1.Close())
+	require.ErrorIs(t, w2.Close(), remotefilez.ErrPrecondition)
+	fi2, err := p.Stat(ctx, furi+"2")
+	require.NoError(t, err)
+	require.Equal(t, "text/plain", fi2.ContentType)
+
+	fi, err := p.Stat(ctx, furi)
+	require.NoError(t, err)
+	require.ErrorIs(t, write(&remotefilez.WriterOptions{IfMatch: `"x"`}, "c"),
+		remotefilez.ErrPrecondition)
+	require.NoError(t, write(&remotefilez.WriterOptions{IfMatch: fi.ETag}, "c"))
+	data, err := os.ReadFile(dir + "/out")
+	require.NoError(t, err)
+	require.Equal(t, "c", string(data))
+
+	// No temporary files are left behind, only the sidecar of out2
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+}
+
+func TestLocalWriterChecksums(t *testing.T) {
+	furi := "file://" + t.TempDir() + "/out"
+	ctx := context.Background()
+	p := (&remotefilez.Opener{}).WithChecksums(true)
+
+	w, err := p.OpenWriter(furi)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("hello "))
+	require.NoError(t, err)
+	_, err = io.Copy(w, bytes.NewReader([]byte("world")))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	fi, err := p.Stat(ctx, furi)
+	require.NoError(t, err)
+	want := md5.Sum([]byte("hello world"))
+	require.Equal(t, want[:], fi.ContentMD5)
+}
+
+func TestLocalWriterRetention(t *testing.T) {
+	dir := t.TempDir()
+	furi := "file://" + dir + "/out"
+	ctx := context.Background()
+	var p remotefilez.Opener
+
+	// Access tiers do not apply to local files
+	w, err := p.OpenWriterWithOptions(ctx, furi, &remotefilez.WriterOptions{AccessTier: "Cool"})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.ErrorIs(t, p.SetTier(ctx, furi, "Cool"), remotefilez.ErrNotImplemented)
+
+	// Retention cannot be enforced for local files
+	_, err = p.OpenWriterWithOptions(ctx, furi, &remotefilez.WriterOptions{LegalHold: true})
+	require.ErrorIs(t, err, remotefilez.ErrNotImplemented)
+}
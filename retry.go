@@ -0,0 +1,75 @@
+package remotefilez
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RetryPolicy controls how remote readers recover from broken download
+// streams, e.g. connection resets or timeouts in the middle of a read. A
+// broken stream is reopened at the current offset, pinned to the version of
+// the file which was opened.
+//
+// Zero-valued fields are replaced by their defaults. Set MaxRetries to a
+// negative value to disable retries.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of consecutive reconnection attempts
+	// before the read fails. Defaults to 3.
+	MaxRetries int
+
+	// MinBackoff is the delay before the first reconnection attempt. The delay
+	// is doubled for each consecutive attempt. Defaults to 500ms.
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the delay between reconnection attempts. Defaults to 30s.
+	MaxBackoff time.Duration
+}
+
+var defaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	MinBackoff: 500 * time.Millisecond,
+	MaxBackoff: 30 * time.Second,
+}
+
+// withDefaults returns a copy of the policy with zero-valued fields replaced by
+// their defaults.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxRetries == 0 {
+		p.MaxRetries = defaultRetryPolicy.MaxRetries
+	}
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = defaultRetryPolicy.MinBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultRetryPolicy.MaxBackoff
+	}
+	return p
+}
+
+// retryable returns true if the failed attempt should be retried. Callers are
+// responsible for not retrying operations whose context is done.
+func (p RetryPolicy) retryable(err error, attempt int) bool {
+	if attempt >= p.MaxRetries {
+		return false
+	}
+	return !errors.Is(err, ErrObjectChanged)
+}
+
+// wait waits before retrying the provided attempt, or until ctx is done.
+func (p RetryPolicy) wait(ctx context.Context, attempt int) error {
+	d := p.MinBackoff
+	for i := 0; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxBackoff)
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,246 @@
+package remotefilez
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		require.Equal(t, defaultRetryPolicy, RetryPolicy{}.withDefaults())
+		p := RetryPolicy{MaxRetries: -1, MinBackoff: time.Second}.withDefaults()
+		require.Equal(t, -1, p.MaxRetries)
+		require.Equal(t, time.Second, p.MinBackoff)
+		require.Equal(t, defaultRetryPolicy.MaxBackoff, p.MaxBackoff)
+	})
+
+	t.Run("retryable", func(t *testing.T) {
+		p := RetryPolicy{MaxRetries: 2}
+		broken := io.ErrUnexpectedEOF
+		require.True(t, p.retryable(broken, 0))
+		require.True(t, p.retryable(broken, 1))
+		require.False(t, p.retryable(broken, 2))
+		changed := fmt.Errorf("%w, etag mismatch", ErrObjectChanged)
+		require.False(t, p.retryable(changed, 0))
+		require.False(t, RetryPolicy{MaxRetries: -1}.retryable(broken, 0))
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		p := RetryPolicy{MinBackoff: 10 * time.Millisecond, MaxBackoff: 40 * time.Millisecond}
+		for attempt, want := range []time.Duration{10, 20, 40, 40} {
+			start := time.Now()
+			require.NoError(t, p.wait(context.Background(), attempt))
+			require.GreaterOrEqual(t, time.Since(start), want*time.Millisecond)
+		}
+	})
+
+	t.Run("backoff aborted by context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		p := RetryPolicy{MinBackoff: time.Hour, MaxBackoff: time.Hour}
+		require.ErrorIs(t, p.wait(ctx, 0), context.Canceled)
+	})
+}
+
+// flakyBlob serves data from memory, breaking each download stream after
+// breakAfter bytes. The first failures downloads fail without any data.
+type flakyBlob struct {
+	data       []byte
+	breakAfter int
+	failures   int
+	downloads  int
+	mtx        sync.Mutex
+}
+
+func (b *flakyBlob) DownloadStream(
+	_ context.Context,
+	o *blob.DownloadStreamOptions,
+) (blob.DownloadStreamResponse, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.downloads++
+	var resp blob.DownloadStreamResponse
+	body := b.data[o.Range.Offset:]
+	if o.Range.Count > 0 {
+		body = body[:o.Range.Count]
+	}
+	var r io.Reader = bytes.NewReader(body)
+	switch {
+	case b.failures > 0:
+		b.failures--
+		r = &brokenReader{}
+	case b.breakAfter > 0 && len(body) > b.breakAfter:
+		r = io.MultiReader(bytes.NewReader(body[:b.breakAfter]), &brokenReader{})
+	}
+	resp.Body = io.NopCloser(r)
+	return resp, nil
+}
+
+func (b *flakyBlob) URL() string {
+	return "https://acct.blob.core.windows.net/c/flaky"
+}
+
+// brokenReader is a broken connection.
+type brokenReader struct{}
+
+func (brokenReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestAzReaderResume(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	policy := RetryPolicy{MaxRetries: 1, MinBackoff: time.Microsecond, MaxBackoff: time.Microsecond}
+	newReader := func(b *flakyBlob) *azReader {
+		b.data = data
+		return &azReader{blob: b, n: int64(len(data)), retry: policy}
+	}
+
+	t.Run("read resumes at offset", func(t *testing.T) {
+		b := &flakyBlob{breakAfter: 64}
+		got, err := io.ReadAll(newReader(b))
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+		require.Greater(t, b.downloads, len(data)/64)
+	})
+
+	t.Run("read retries broken streams", func(t *testing.T) {
+		got, err := io.ReadAll(newReader(&flakyBlob{failures: 1}))
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+
+		_, err = io.ReadAll(newReader(&flakyBlob{failures: 2}))
+		require.Error(t, err)
+	})
+
+	t.Run("readRange resets failures on progress", func(t *testing.T) {
+		// Every stream breaks, but each makes progress
+		sc := newReader(&flakyBlob{breakAfter: 64})
+		p := make([]byte, 500)
+		n, err := sc.readRange(context.Background(), p, 100)
+		require.NoError(t, err)
+		require.Equal(t, 500, n)
+		require.Equal(t, data[100:600], p)
+
+		// Streams which make no progress exhaust the retries
+		sc = newReader(&flakyBlob{failures: 2})
+		_, err = sc.readRange(context.Background(), p, 100)
+		require.Error(t, err)
+	})
+}
+
+// stallingBlob serves data from memory. The first stalls download streams
+// block until their download is aborted, like a stalled connection.
+type stallingBlob struct {
+	data      []byte
+	mtx       sync.Mutex
+	stalls    int
+	downloads int
+}
+
+func (b *stallingBlob) DownloadStream(
+	ctx context.Context,
+	o *blob.DownloadStreamOptions,
+) (blob.DownloadStreamResponse, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.downloads++
+	var resp blob.DownloadStreamResponse
+	body := b.data[o.Range.Offset:]
+	if o.Range.Count > 0 {
+		body = body[:o.Range.Count]
+	}
+	var r io.Reader = bytes.NewReader(body)
+	if b.stalls > 0 {
+		b.stalls--
+		r = stalledReader{ctx: ctx}
+	}
+	resp.Body = io.NopCloser(r)
+	return resp, nil
+}
+
+func (b *stallingBlob) URL() string {
+	return "https://acct.blob.core.windows.net/c/stalling"
+}
+
+// stalledReader blocks until ctx is done.
+type stalledReader struct {
+	ctx context.Context
+}
+
+func (r stalledReader) Read([]byte) (int, error) {
+	<-r.ctx.Done()
+	return 0, r.ctx.Err()
+}
+
+func TestAzReaderContext(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	newReader := func(b *stallingBlob) *azReader {
+		b.data = data
+		return &azReader{blob: b, n: int64(len(data)), retry: defaultRetryPolicy}
+	}
+	cancelSoon := func() context.Context {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return ctx
+	}
+
+	t.Run("cancel interrupts blocked read", func(t *testing.T) {
+		r := newReader(&stallingBlob{stalls: 1})
+		_, err := r.ReadCtx(cancelSoon(), make([]byte, 10))
+		require.ErrorIs(t, err, context.Canceled)
+
+		// A later read with a fresh context reopens the stream
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+	})
+
+	t.Run("cancel interrupts blocked read at", func(t *testing.T) {
+		r := newReader(&stallingBlob{stalls: 1})
+		_, err := r.ReadAtCtx(cancelSoon(), make([]byte, 10), 100)
+		require.ErrorIs(t, err, context.Canceled)
+
+		buf := make([]byte, 10)
+		_, err = r.ReadAtCtx(context.Background(), buf, 100)
+		require.NoError(t, err)
+		require.Equal(t, data[100:110], buf)
+	})
+
+	t.Run("expired context does not affect later reads", func(t *testing.T) {
+		// The stream opened by the first read outlives its context
+		b := &stallingBlob{}
+		r := newReader(b)
+		ctx, cancel := context.WithCancel(context.Background())
+		buf := make([]byte, 10)
+		_, err := r.ReadCtx(ctx, buf)
+		require.NoError(t, err)
+		cancel()
+		rest, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, data, append(buf, rest...))
+		require.Equal(t, 1, b.downloads)
+
+		// Seeking never blocks, so an expired context does not fail it
+		off, err := r.SeekCtx(ctx, 100, io.SeekStart)
+		require.NoError(t, err)
+		require.EqualValues(t, 100, off)
+		rest, err = io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, data[100:], rest)
+	})
+}
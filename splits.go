@@ -0,0 +1,99 @@
+package remotefilez
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+)
+
+const splitProbeSize = 64 << 10
+
+// PlanSplits divides the file at the provided URL into ranges of roughly
+// splitSize bytes which are aligned to record boundaries, so that each range
+// can be processed independently, e.g. by passing it to OpenRange.
+//
+// Records are terminated by delim, which defaults to a newline. Each range
+// starts at the beginning of a record and ends right after a delimiter (or at
+// the end of the file). Boundaries are found by reading small windows around
+// each cut point, so the file is never read in full.
+func (ro *Opener) PlanSplits(
+	ctx context.Context,
+	fileURL string,
+	splitSize int64,
+	delim []byte,
+) ([]ByteRange, error) {
+	if splitSize <= 0 {
+		return nil, errors.New("split size must be positive")
+	}
+	if len(delim) == 0 {
+		delim = []byte{'\n'}
+	}
+	// Probing reads a few small windows, which must not download the whole
+	// file into the disk cache
+	noCache := *ro
+	noCache.diskCache = nil
+	r, err := noCache.OpenReaderCtx(ctx, fileURL)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	n, err := r.Size()
+	if err != nil {
+		return nil, err
+	}
+	fetch := rangeFetcher(r)
+
+	var splits []ByteRange
+	var start int64
+	for cut := splitSize; cut < n; cut += splitSize {
+		if cut <= start {
+			// The previous record spanned this cut point
+			continue
+		}
+		end, err := nextRecord(ctx, fetch, n, cut, delim)
+		if err != nil {
+			return nil, err
+		}
+		if end >= n {
+			break
+		}
+		splits = append(splits, ByteRange{Offset: start, Length: end - start})
+		start = end
+	}
+	if start < n || len(splits) == 0 {
+		splits = append(splits, ByteRange{Offset: start, Length: n - start})
+	}
+	return splits, nil
+}
+
+// nextRecord returns the offset of the first record which starts at or after
+// off in a file of size n, or n if there is no such record.
+func nextRecord(
+	ctx context.Context,
+	fetch rangeFetchFunc,
+	n, off int64,
+	delim []byte,
+) (int64, error) {
+	// A delimiter which ends right at off means a record starts at off.
+	pos := off - int64(len(delim))
+	if pos < 0 {
+		pos = 0
+	}
+	buf := make([]byte, splitProbeSize+len(delim))
+	for pos < n {
+		m, err := fetch(ctx, buf, pos)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.Index(buf[:m], delim); i >= 0 {
+			return pos + int64(i+len(delim)), nil
+		}
+		if err == io.EOF || m < len(delim) {
+			break
+		}
+		// Overlap windows so that delimiters across window edges are found
+		pos += int64(m - len(delim) + 1)
+	}
+	return n, nil
+}
@@ -0,0 +1,85 @@
+package remotefilez
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// FileInfo describes a local or remote file.
+type FileInfo struct {
+	Size            int64
+	ModTime         time.Time
+	ETag            string
+	ContentType     string
+	ContentEncoding string
+	ContentMD5      []byte
+
+	// Metadata contains user-defined metadata.
+	Metadata map[string]string
+
+	// Extra contains backend-specific properties, e.g. the access tier of an
+	// Azure blob.
+	Extra map[string]string
+}
+
+// Stater is implemented by file handles returned by the Opener which can
+// describe the underlying file. The method is not named Stat so that local
+// file handles keep the Stat method of os.File.
+type Stater interface {
+	FileInfo() (FileInfo, error)
+}
+
+// Stat returns information about the file at the provided URL without opening
+// it for reading.
+func (ro *Opener) Stat(ctx context.Context, fileURL string) (FileInfo, error) {
+	u, err := parseFileURL(fileURL)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	switch u.Scheme {
+	case schemeFile:
+		fi, err := os.Stat(u.Path)
+		if err != nil {
+			return FileInfo{}, err
+		}
+		return localFileInfo(u.Path, fi)
+	case schemeAzure:
+		if ro.azcreds == nil {
+			return FileInfo{}, errors.New("missing credentials please add AzureResolver")
+		}
+		return statAzureBlob(ctx, fileURL, ro.azcreds)
+	default:
+		return FileInfo{}, fmt.Errorf("%w %q", ErrUnsupportedScheme, u.Scheme)
+	}
+}
+
+// localFileInfo converts os.FileInfo of the file at path to FileInfo. Local
+// files have no ETag, so one is derived from the modification time and size
+// of the file. Content headers and metadata are read from the sidecar file
+// written by the Opener, if any.
+func localFileInfo(path string, fi os.FileInfo) (FileInfo, error) {
+	meta, err := readLocalMeta(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	info := FileInfo{
+		Size:            fi.Size(),
+		ModTime:         fi.ModTime(),
+		ETag:            fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()),
+		ContentType:     meta.ContentType,
+		ContentEncoding: meta.ContentEncoding,
+		ContentMD5:      meta.ContentMD5,
+		Metadata:        meta.Metadata,
+		Extra: map[string]string{
+			"Mode": fi.Mode().String(),
+		},
+	}
+	if meta.CacheControl != "" {
+		info.Extra["CacheControl"] = meta.CacheControl
+	}
+	return info, nil
+}
@@ -0,0 +1,37 @@
+package remotefilez
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+)
+
+// SetTier changes the access tier of the file at the provided URL, e.g. to
+// "Hot", "Cool", "Cold" or "Archive". Moving a blob out of the archive tier
+// starts a rehydration, which may take hours to complete. Local files have no
+// access tiers.
+func (ro *Opener) SetTier(ctx context.Context, fileURL string, tier string) error {
+	u, err := parseFileURL(fileURL)
+	if err != nil {
+		return err
+	}
+
+	switch u.Scheme {
+	case schemeFile:
+		return fmt.Errorf("%w, local files have no access tier", ErrNotImplemented)
+	case schemeAzure:
+		if ro.azcreds == nil {
+			return errors.New("missing credentials please add AzureResolver")
+		}
+		blobClient, err := newBlobClient(fileURL, ro.azcreds)
+		if err != nil {
+			return err
+		}
+		_, err = blobClient.SetTier(ctx, blob.AccessTier(tier), nil)
+		return err
+	default:
+		return fmt.Errorf("%w %q", ErrUnsupportedScheme, u.Scheme)
+	}
+}
@@ -0,0 +1,194 @@
+package remotefilez
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	defaultChunkSize   = 8 << 20
+	defaultConcurrency = 4
+)
+
+// DownloadOptions contains optional parameters for Opener.Download.
+type DownloadOptions struct {
+	// ChunkSize is the size of each ranged request. Defaults to 8 MiB.
+	ChunkSize int64
+
+	// Concurrency is the maximum number of concurrent ranged requests.
+	// Defaults to 4.
+	Concurrency int
+}
+
+// Download downloads the file at srcURL to the local file at dstPath. The file
+// is split into chunks which are fetched concurrently and written into place
+// in a preallocated temporary file, which replaces the local file once the
+// download is complete. If the download fails, the local file is left
+// untouched. Missing parent directories are created as for OpenWriter.
+func (ro *Opener) Download(
+	ctx context.Context,
+	srcURL string,
+	dstPath string,
+	opts *DownloadOptions,
+) error {
+	src, err := ro.OpenReaderCtx(ctx, srcURL)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return ro.download(ctx, src, dstPath, opts)
+}
+
+// download downloads src to the local file at dstPath. If src is verifiable,
+// the download is verified before it replaces the local file.
+func (ro *Opener) download(
+	ctx context.Context,
+	src ReaderAtSeekCloser,
+	dstPath string,
+	opts *DownloadOptions,
+) error {
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+	n, err := src.Size()
+	if err != nil {
+		return err
+	}
+
+	if err := ro.mkdirAll(dstPath); err != nil {
+		return err
+	}
+	f, err := createTemp(dstPath)
+	if err != nil {
+		return err
+	}
+	var verifier *readVerifier
+	if v, ok := src.(verifiable); ok {
+		verifier = v.newVerifier()
+	}
+	err = f.Truncate(n)
+	if err == nil {
+		// Chunks are passed on in order, so they can be verified as they are
+		// written
+		err = fetchChunks(ctx, rangeFetcher(src), 0, n,
+			opts.ChunkSize, opts.Concurrency,
+			func(p []byte, off int64) error {
+				verifier.add(p, off)
+				_, err := f.WriteAt(p, off)
+				return err
+			},
+		)
+	}
+	if err == nil {
+		err = verifier.verify()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// Properties of a previous local file do not describe the download
+		err = writeLocalMeta(dstPath, nil)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), dstPath)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(dstPath))
+}
+
+type rangeFetchFunc func(ctx context.Context, p []byte, off int64) (int, error)
+
+// rangeFetcher returns a function which fetches byte ranges of r. Remote files
+// are fetched with dedicated ranged requests which are safe for concurrent use.
+// Other files are expected to have a concurrency-safe ReadAt.
+func rangeFetcher(r ReaderAtSeekCloser) rangeFetchFunc {
+	switch r := r.(type) {
+	case rangeReader:
+		return r.readRange
+	case *blockReader:
+		return r.src.readRange
+	}
+	return func(_ context.Context, p []byte, off int64) (int, error) {
+		return r.ReadAt(p, off)
+	}
+}
+
+type chunk struct {
+	off  int64
+	buf  []byte
+	err  error
+	done chan struct{}
+}
+
+// fetchChunks fetches the byte range [off, end) in chunks of chunkSize bytes,
+// with up to concurrency chunks in flight at a time. fn is called with each
+// chunk in order of increasing offset. The first error returned by fetch or fn
+// stops the transfer and is returned.
+func fetchChunks(
+	ctx context.Context,
+	fetch rangeFetchFunc,
+	off, end int64,
+	chunkSize int64,
+	concurrency int,
+	fn func(p []byte, off int64) error,
+) error {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Chunks are fetched by the producer below once they have been queued, so
+	// the queue (plus the chunk being consumed) bounds the number of chunks in
+	// flight.
+	queue := make(chan *chunk, concurrency-1)
+	go func() {
+		defer close(queue)
+		for o := off; o < end; o += chunkSize {
+			c := &chunk{
+				off:  o,
+				buf:  make([]byte, min(chunkSize, end-o)),
+				done: make(chan struct{}),
+			}
+			select {
+			case queue <- c:
+			case <-ctx.Done():
+				return
+			}
+			go func() {
+				defer close(c.done)
+				n, err := fetch(ctx, c.buf, c.off)
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+					if n == len(c.buf) {
+						err = nil
+					}
+				}
+				c.buf = c.buf[:n]
+				c.err = err
+			}()
+		}
+	}()
+
+	for c := range queue {
+		<-c.done
+		if c.err != nil {
+			return c.err
+		}
+		if err := fn(c.buf, c.off); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
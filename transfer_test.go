@@ -0,0 +1,62 @@
+package remotefilez
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchChunks(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	src := &memRangeReader{data: data}
+	ctx := context.Background()
+
+	t.Run("in order", func(t *testing.T) {
+		var got bytes.Buffer
+		var offs []int64
+		err := fetchChunks(ctx, src.readRange, 100, 1000, 64, 3,
+			func(p []byte, off int64) error {
+				offs = append(offs, off)
+				got.Write(p)
+				return nil
+			},
+		)
+		require.NoError(t, err)
+		require.Equal(t, data[100:], got.Bytes())
+		require.Len(t, offs, 15)
+		require.EqualValues(t, 100, offs[0])
+		require.EqualValues(t, 996, offs[14])
+	})
+
+	t.Run("stops on error", func(t *testing.T) {
+		errStop := errors.New("stop")
+		var calls int
+		err := fetchChunks(ctx, src.readRange, 0, 1000, 64, 3,
+			func(p []byte, off int64) error {
+				calls++
+				return errStop
+			},
+		)
+		require.ErrorIs(t, err, errStop)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("block reader write to", func(t *testing.T) {
+		br, err := newBlockReader(src, 64, 2, nil)
+		require.NoError(t, err)
+		_, err = br.Seek(10, io.SeekStart)
+		require.NoError(t, err)
+		var got bytes.Buffer
+		n, err := io.Copy(&got, br)
+		require.NoError(t, err)
+		require.EqualValues(t, 990, n)
+		require.Equal(t, data[10:], got.Bytes())
+	})
+}
@@ -0,0 +1,232 @@
+package remotefilez
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// Azure block blob limits
+const (
+	azMaxBlocks    = 50000
+	azMinBlockSize = 1 << 20
+	azMaxBlockSize = 4000 << 20
+)
+
+const (
+	defaultUploadBlockSize   = 8 << 20
+	defaultUploadConcurrency = 4
+)
+
+// WriteAborter is a writer which commits written data when it is closed, and
+// can be aborted without committing anything.
+type WriteAborter interface {
+	io.WriteCloser
+
+	// CloseWithError aborts the write, leaving any existing file at the target
+	// untouched. Subsequent writes fail with err, or ErrWriteAborted if err is
+	// nil. It is safe to call CloseWithError concurrently with Write, e.g. to
+	// abort a write which is blocked.
+	CloseWithError(err error) error
+}
+
+// Resumer is implemented by writers which can resume an upload. Writes to a
+// resumed writer continue at ResumeOffset, i.e. callers must skip the first
+// ResumeOffset bytes of the data they would otherwise write.
+type Resumer interface {
+	ResumeOffset() int64
+}
+
+// WriterOptions contains optional parameters for opening writers.
+type WriterOptions struct {
+	// BlockSize is the size of each block uploaded to Azure. Defaults to 8 MiB,
+	// or to the smallest block size which fits Size within the block count
+	// limit of Azure block blobs.
+	BlockSize int64
+
+	// Concurrency is the maximum number of blocks uploaded in parallel.
+	// Defaults to 4.
+	Concurrency int
+
+	// MaxMemory limits the memory used for upload buffers by reducing
+	// Concurrency. At least one block is always buffered.
+	MaxMemory int64
+
+	// Size is the expected size of the file, if known. It is used to choose
+	// a block size.
+	Size int64
+
+	// ContentType is the MIME type of the file. If empty and
+	// DetectContentType is set, the type is inferred from the file extension.
+	ContentType       string
+	DetectContentType bool
+
+	// ContentEncoding and CacheControl set the corresponding HTTP headers of
+	// the file.
+	ContentEncoding string
+	CacheControl    string
+
+	// Metadata contains user-defined metadata.
+	Metadata map[string]string
+
+	// Tags contains Azure blob index tags. They are ignored for local files.
+	Tags map[string]string
+
+	// IfNotExists makes the write fail with ErrPrecondition if the file
+	// already exists.
+	IfNotExists bool
+
+	// IfMatch makes the write fail with ErrPrecondition unless the file
+	// exists and has the provided ETag, as returned by Stat.
+	//
+	// Preconditions are checked both when the writer is opened and when it is
+	// committed. Azure enforces them atomically, whereas local writes can race
+	// with writers which do not use the Opener.
+	IfMatch string
+
+	// Journal is the path of a local file in which the progress of an Azure
+	// upload is recorded. If the upload fails, or the process dies, a new
+	// writer with the same journal resumes after the data which was already
+	// uploaded, see Resumer. The journal is removed once the upload is
+	// committed.
+	Journal string
+
+	// AccessTier is the access tier of an Azure blob, e.g. "Hot", "Cool",
+	// "Cold" or "Archive". Defaults to the default tier of the storage
+	// account. It is ignored for local files.
+	AccessTier string
+
+	// LegalHold places a legal hold on an Azure blob, which prevents it from
+	// being modified or deleted until the hold is cleared.
+	LegalHold bool
+
+	// ImmutableUntil sets a time-based immutability policy on an Azure blob,
+	// which prevents it from being modified or deleted until the provided
+	// time. The policy is unlocked unless LockImmutability is set, in which
+	// case it can never be shortened or removed.
+	//
+	// Legal holds and immutability policies require version-level
+	// immutability support on the container, and are not supported for local
+	// files.
+	ImmutableUntil   time.Time
+	LockImmutability bool
+
+	// checksum is set by Opener.WithChecksums.
+	checksum bool
+}
+
+// contentType returns the content type of the file at the provided path.
+func (o *WriterOptions) contentType(p string) string {
+	if o.ContentType == "" && o.DetectContentType {
+		return mime.TypeByExtension(path.Ext(p))
+	}
+	return o.ContentType
+}
+
+// blockSize returns the block size to use for uploads.
+func (o *WriterOptions) blockSize() (int64, error) {
+	if o.BlockSize > 0 {
+		if o.BlockSize > azMaxBlockSize {
+			return 0, fmt.Errorf("block size %v exceeds the maximum of %v", o.BlockSize, azMaxBlockSize)
+		}
+		if o.Size > o.BlockSize*azMaxBlocks {
+			return 0, fmt.Errorf("block size %v is too small for %v bytes", o.BlockSize, o.Size)
+		}
+		return o.BlockSize, nil
+	}
+	bs := int64(defaultUploadBlockSize)
+	if o.Size > bs*azMaxBlocks {
+		// Round up to the nearest MiB
+		bs = (o.Size + azMaxBlocks - 1) / azMaxBlocks
+		bs = (bs + azMinBlockSize - 1) / azMinBlockSize * azMinBlockSize
+	}
+	if bs > azMaxBlockSize {
+		return 0, fmt.Errorf("%v bytes exceeds the maximum blob size", o.Size)
+	}
+	return bs, nil
+}
+
+// concurrency returns the number of blocks to upload in parallel.
+func (o *WriterOptions) concurrency(blockSize int64) int {
+	c := o.Concurrency
+	if c <= 0 {
+		c = defaultUploadConcurrency
+	}
+	if o.MaxMemory > 0 {
+		c = int(min(int64(c), o.MaxMemory/blockSize))
+	}
+	if c < 1 {
+		c = 1
+	}
+	return c
+}
+
+// OpenWriterWithOptions returns an io.WriteCloser handle to the provided file
+// URL, configured by opts. A nil opts is equivalent to the zero value.
+//
+// Written data becomes visible at the target only once the writer is closed
+// successfully. Call CloseWithError to discard it instead. Local targets which
+// are not regular files, such as FIFOs and devices, are written in place and
+// cannot be aborted. Local symlinks are followed.
+func (ro *Opener) OpenWriterWithOptions(
+	ctx context.Context,
+	fileURL string,
+	opts *WriterOptions,
+) (WriteAborter, error) {
+	var o WriterOptions
+	if opts != nil {
+		o = *opts
+	}
+	opts = &o
+	opts.checksum = ro.checksums
+	if opts.IfNotExists && opts.IfMatch != "" {
+		return nil, errors.New("IfNotExists and IfMatch are mutually exclusive")
+	}
+	u, err := parseFileURL(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Fragment != "" {
+		return nil, fmt.Errorf("%w, ranges cannot be written", ErrInvalidRange)
+	}
+
+	switch u.Scheme {
+	case schemeFile:
+		if opts.Journal != "" {
+			return nil, fmt.Errorf("%w, journals are only supported for remote files", ErrNotImplemented)
+		}
+		if opts.LegalHold || !opts.ImmutableUntil.IsZero() {
+			return nil, fmt.Errorf("%w, immutability is only supported for remote files", ErrNotImplemented)
+		}
+		if err := ro.mkdirAll(u.Path); err != nil {
+			return nil, err
+		}
+		return newLocalWriter(u.Path, opts)
+	case schemeAzure:
+		if ro.azcreds == nil {
+			return nil, errors.New("missing credentials please add AzureResolver")
+		}
+		return newAzureBlobWriter(ctx, fileURL, ro.azcreds, opts)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedScheme, u.Scheme)
+	}
+}
+
+// mkdirAll creates the missing parent directories of the local file at path,
+// unless disabled by WithParentDirs.
+func (ro *Opener) mkdirAll(path string) error {
+	if ro.noMkdirAll {
+		return nil
+	}
+	perm := ro.dirPerm
+	if perm == 0 {
+		perm = 0777
+	}
+	return os.MkdirAll(filepath.Dir(path), perm)
+}
@@ -0,0 +1,131 @@
+package remotefilez
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		opts    WriterOptions
+		bs      int64
+		c       int
+		wantErr bool
+	}{
+		{"defaults", WriterOptions{}, 8 << 20, 4, false},
+		{"small size", WriterOptions{Size: 1 << 20}, 8 << 20, 4, false},
+		{"large size", WriterOptions{Size: 1 << 40}, 21 << 20, 4, false},
+		{"explicit", WriterOptions{BlockSize: 1 << 20, Concurrency: 16}, 1 << 20, 16, false},
+		{"memory budget", WriterOptions{MaxMemory: 20 << 20}, 8 << 20, 2, false},
+		{"tiny memory budget", WriterOptions{MaxMemory: 1}, 8 << 20, 1, false},
+		{"block size too large", WriterOptions{BlockSize: azMaxBlockSize + 1}, 0, 0, true},
+		{"block size too small", WriterOptions{BlockSize: 1 << 20, Size: 1 << 40}, 0, 0, true},
+		{"size too large", WriterOptions{Size: 1 << 50}, 0, 0, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			bs, err := tc.opts.blockSize()
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.bs, bs)
+			require.Equal(t, tc.c, tc.opts.concurrency(bs))
+		})
+	}
+}
+
+func TestAzWriter(t *testing.T) {
+	ctx := context.Background()
+	newWriter := func(t *testing.T, s blockStager, opts *WriterOptions) *azWriter {
+		up, err := newBlockUpload(ctx, s, "https://acct.blob.core.windows.net/c/blob", opts, 4, nil, nil, nil)
+		require.NoError(t, err)
+		return &azWriter{up: up, ctx: ctx, resumeOff: up.off}
+	}
+
+	t.Run("write fails fast", func(t *testing.T) {
+		stageErr := errors.New("stage failed")
+		sc := newWriter(t, &memStager{err: stageErr}, &WriterOptions{Concurrency: 1})
+		_, err := sc.Write(make([]byte, 12))
+		require.ErrorIs(t, err, stageErr)
+		_, err = sc.Write(make([]byte, 12))
+		require.ErrorIs(t, err, stageErr)
+		require.ErrorIs(t, sc.Close(), stageErr)
+	})
+
+	t.Run("close waits for upload", func(t *testing.T) {
+		s := &memStager{delay: 10 * time.Millisecond}
+		sc := newWriter(t, s, &WriterOptions{})
+		_, err := sc.Write([]byte("hello"))
+		require.NoError(t, err)
+		_, err = sc.ReadFrom(bytes.NewReader([]byte(" world")))
+		require.NoError(t, err)
+		require.NoError(t, sc.Close())
+		require.Equal(t, "hello world", string(s.committed))
+		require.ErrorIs(t, sc.Close(), os.ErrClosed)
+	})
+
+	t.Run("read from stages full blocks", func(t *testing.T) {
+		s := &memStager{}
+		sc := newWriter(t, s, &WriterOptions{Concurrency: 1})
+		n, err := io.Copy(sc, iotest.OneByteReader(bytes.NewReader([]byte("hello world"))))
+		require.NoError(t, err)
+		require.Equal(t, int64(11), n)
+		require.NoError(t, sc.Close())
+		require.Equal(t, "hello world", string(s.committed))
+		require.Equal(t, []int{4, 4, 3}, s.sizes)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		s := &memStager{}
+		sc := newWriter(t, s, &WriterOptions{})
+		_, err := sc.Write([]byte("partial"))
+		require.NoError(t, err)
+		require.NoError(t, sc.CloseWithError(nil))
+		_, err = sc.Write([]byte("more"))
+		require.ErrorIs(t, err, ErrWriteAborted)
+		require.ErrorIs(t, sc.Close(), ErrWriteAborted)
+		require.Nil(t, s.committed)
+	})
+
+	t.Run("abort unblocks write", func(t *testing.T) {
+		s := &memStager{release: make(chan struct{})}
+		sc := newWriter(t, s, &WriterOptions{Concurrency: 1})
+		done := make(chan error)
+		go func() {
+			_, err := sc.Write(make([]byte, 12))
+			done <- err
+		}()
+		time.AfterFunc(10*time.Millisecond, func() { close(s.release) })
+		require.NoError(t, sc.CloseWithError(nil))
+		require.ErrorIs(t, <-done, ErrWriteAborted)
+	})
+	t.Run("resume after abort", func(t *testing.T) {
+		s := &memStager{delay: 10 * time.Millisecond}
+		opts := &WriterOptions{Journal: filepath.Join(t.TempDir(), "journal")}
+		sc := newWriter(t, s, opts)
+		require.Zero(t, sc.ResumeOffset())
+		_, err := sc.Write([]byte("hello"))
+		require.NoError(t, err)
+		// The first block is still being staged when the write is aborted
+		require.NoError(t, sc.CloseWithError(nil))
+
+		sc = newWriter(t, s, opts)
+		require.Equal(t, int64(4), sc.ResumeOffset())
+		_, err = sc.Write([]byte("hello world")[sc.ResumeOffset():])
+		require.NoError(t, err)
+		require.NoError(t, sc.Close())
+		require.Equal(t, "hello world", string(s.committed))
+		require.NoFileExists(t, opts.Journal)
+	})
+}